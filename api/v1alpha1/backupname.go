@@ -0,0 +1,97 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultBackupNameTemplate is the template used to generate the names of backups that
+	// do not set one, unless it is overridden with SetBackupNameTemplate.
+	DefaultBackupNameTemplate = "{{.Datacenter}}-{{.Type}}-{{.Timestamp}}"
+
+	// GeneratedBackupNameAnnotation is set on a CassandraBackup whose name has been
+	// generated. Generated names are made unique against the backups in storage before
+	// the backup is started.
+	GeneratedBackupNameAnnotation = "cassandra.k8ssandra.io/generated-backup-name"
+
+	backupNameTimestampFormat = "20060102150405"
+)
+
+var backupNameTemplate = template.Must(template.New("backupName").Option("missingkey=error").Parse(DefaultBackupNameTemplate))
+
+// backupNameFields are the fields that can be used in the backup name template.
+type backupNameFields struct {
+	Namespace  string
+	Datacenter string
+	Type       BackupType
+	// The time at which the backup was created, formatted as 20060102150405 in UTC.
+	Timestamp string
+}
+
+// SetBackupNameTemplate replaces the template used to generate backup names. It must be
+// called before the manager is started.
+func SetBackupNameTemplate(text string) error {
+	tmpl, err := template.New("backupName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+	if _, err := executeBackupNameTemplate(tmpl, backupNameFields{}); err != nil {
+		return err
+	}
+	backupNameTemplate = tmpl
+	return nil
+}
+
+// GenerateBackupName returns a name for the backup from the backup name template, using t
+// as the creation time.
+func GenerateBackupName(backup *CassandraBackup, t time.Time) (string, error) {
+	backupType := backup.Spec.Type
+	if len(backupType) == 0 {
+		backupType = DifferentialBackup
+	}
+	return executeBackupNameTemplate(backupNameTemplate, backupNameFields{
+		Namespace:  backup.Namespace,
+		Datacenter: backup.Spec.CassandraDatacenter,
+		Type:       backupType,
+		Timestamp:  t.UTC().Format(backupNameTimestampFormat),
+	})
+}
+
+// GenerateClusterBackupName returns a name for the cluster backup from the backup name
+// template, using the name of the cluster as the datacenter and t as the creation time.
+func GenerateClusterBackupName(backup *CassandraClusterBackup, t time.Time) (string, error) {
+	return GenerateBackupName(&CassandraBackup{
+		ObjectMeta: backup.ObjectMeta,
+		Spec: CassandraBackupSpec{
+			CassandraDatacenter: backup.Spec.CassandraCluster,
+			Type:                backup.Spec.Type,
+		},
+	}, t)
+}
+
+func executeBackupNameTemplate(tmpl *template.Template, fields backupNameFields) (string, error) {
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, fields); err != nil {
+		return "", fmt.Errorf("failed to generate backup name: %s", err)
+	}
+	return name.String(), nil
+}
@@ -0,0 +1,112 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateBackupSelector checks that the backup to restore is either named or selected, and
+// that the selector is consistent.
+func (s *CassandraRestoreSpec) ValidateBackupSelector() field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	if len(s.Backup) > 0 && s.BackupSelector != nil {
+		errs = append(errs, field.Forbidden(specPath.Child("backupSelector"), "backup and backupSelector are mutually exclusive"))
+	}
+	if len(s.Backup) == 0 && s.BackupSelector == nil && s.RestorePointInTime == nil {
+		errs = append(errs, field.Required(specPath.Child("backup"), "one of backup, backupSelector or restorePointInTime must be set"))
+	}
+
+	selector := s.BackupSelector
+	if selector == nil {
+		return errs
+	}
+	selectorPath := specPath.Child("backupSelector")
+	if selector.Latest && selector.Before != nil {
+		errs = append(errs, field.Forbidden(selectorPath.Child("before"), "latest and before are mutually exclusive"))
+	}
+	if !selector.Latest && selector.Before == nil {
+		errs = append(errs, field.Required(selectorPath, "one of latest or before must be set"))
+	}
+	if s.InPlace && len(selector.Datacenter) > 0 && selector.Datacenter != s.CassandraDatacenter.Name {
+		errs = append(errs, field.Invalid(selectorPath.Child("datacenter"), selector.Datacenter,
+			fmt.Sprintf("an in place restore must select the backups of the datacenter that is restored (%s)", s.CassandraDatacenter.Name)))
+	}
+	if selector.LabelSelector != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(selector.LabelSelector, selectorPath.Child("labelSelector"))...)
+	}
+	return errs
+}
+
+// BackupDatacenter returns the datacenter whose backups are selected.
+func (s *CassandraRestoreSpec) BackupDatacenter() string {
+	if s.BackupSelector != nil && len(s.BackupSelector.Datacenter) > 0 {
+		return s.BackupSelector.Datacenter
+	}
+	return s.CassandraDatacenter.Name
+}
+
+// BackupDeadline returns the time before which the selected backup must have finished, or
+// nil if there is none. It is the earliest of the before time of the selector and the point
+// in time to restore.
+func (s *CassandraRestoreSpec) BackupDeadline() *metav1.Time {
+	deadline := s.RestorePointInTime
+	if s.BackupSelector != nil && s.BackupSelector.Before != nil && (deadline == nil || s.BackupSelector.Before.Before(deadline)) {
+		deadline = s.BackupSelector.Before
+	}
+	return deadline
+}
+
+// SelectBackup returns the newest successful backup of the datacenter that matches the
+// selector and finished before the deadline. It returns nil if there is none, or if the
+// selector does not set latest or before or has an invalid label selector, which
+// ValidateBackupSelector reports.
+func (s *CassandraRestoreSpec) SelectBackup(backups []CassandraBackup) *CassandraBackup {
+	if s.BackupSelector != nil && !s.BackupSelector.Latest && s.BackupSelector.Before == nil {
+		return nil
+	}
+
+	selector := labels.Everything()
+	if s.BackupSelector != nil && s.BackupSelector.LabelSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(s.BackupSelector.LabelSelector); err != nil {
+			return nil
+		}
+	}
+	datacenter, deadline := s.BackupDatacenter(), s.BackupDeadline()
+
+	var newest *CassandraBackup
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Spec.CassandraDatacenter != datacenter || !backup.IsSucceeded() || !selector.Matches(labels.Set(backup.Labels)) {
+			continue
+		}
+		if deadline != nil && !backup.Status.FinishTime.Before(deadline) {
+			continue
+		}
+		if newest == nil || backup.Status.FinishTime.After(newest.Status.FinishTime.Time) {
+			newest = backup
+		}
+	}
+	return newest
+}
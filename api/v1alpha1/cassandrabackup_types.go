@@ -18,6 +18,8 @@ package v1alpha1
 
 import (
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -32,10 +34,54 @@ const (
 	DifferentialBackup BackupType = "differential"
 )
 
+// DeletionPolicy describes what happens to the backup in the Medusa storage backend when
+// the CassandraBackup is deleted.
+type DeletionPolicy string
+
+const (
+	// RetainBackup keeps the backup in storage when the CassandraBackup is deleted.
+	RetainBackup DeletionPolicy = "Retain"
+
+	// DeleteBackup deletes the backup from storage before the CassandraBackup is removed.
+	DeleteBackup DeletionPolicy = "Delete"
+)
+
+// BackupPhase is a summary of the conditions of a CassandraBackup.
+type BackupPhase string
+
+const (
+	BackupPhasePending   BackupPhase = "Pending"
+	BackupPhaseRunning   BackupPhase = "Running"
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+	BackupPhaseFailed    BackupPhase = "Failed"
+)
+
+const (
+	// BackupConditionStarted is true once the backup has been started on the pods of the
+	// datacenter.
+	BackupConditionStarted = "Started"
+
+	// BackupConditionSucceeded is true once the backup finished on all pods.
+	BackupConditionSucceeded = "Succeeded"
+
+	// BackupConditionFailed is true once the backup finished and failed on at least one pod.
+	BackupConditionFailed = "Failed"
+
+	// BackupConditionPurged reports the outcome of deleting the backup from the Medusa
+	// storage backend.
+	BackupConditionPurged = "Purged"
+
+	// BackupConditionSidecarNotFound is true while the backup cannot be started because
+	// some of the pods of the datacenter do not run the Medusa sidecar.
+	BackupConditionSidecarNotFound = "SidecarNotFound"
+)
+
 // CassandraBackupSpec defines the desired state of CassandraBackup
 type CassandraBackupSpec struct {
-	// The name of the backup.
-	// TODO document format of generated name
+	// The name of the backup. When empty, it is generated from the backup name template of
+	// the operator, which defaults to "{{.Datacenter}}-{{.Type}}-{{.Timestamp}}", e.g.
+	// dc1-full-20211115103000. Generated names are made unique against the backups that
+	// already exist in storage.
 	Name string `json:"name,omitempty"`
 
 	// The name of the CassandraDatacenter to back up
@@ -45,6 +91,294 @@ type CassandraBackupSpec struct {
 	// +kubebuilder:validation:Enum=differential;full;
 	// +kubebuilder:default:=differential
 	Type BackupType `json:"backupType,omitempty"`
+
+	// Whether the backup is deleted from the Medusa storage backend when the
+	// CassandraBackup is deleted: "Retain" or "Delete". Imported backups are always
+	// retained since the backup in storage is shared with the other datacenters. With
+	// "Delete", the cassandra.k8ssandra.io/purge-backup finalizer is removed once the
+	// backup is no longer in storage. If the CassandraDatacenter no longer exists, the
+	// Purged condition is set to false with the DatacenterNotFound reason and the finalizer
+	// must be removed by hand to delete the CassandraBackup.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default:=Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Overrides how the Medusa sidecars are located in the pods of the datacenter. By
+	// default they are discovered from the annotations of the CassandraDatacenter and the
+	// pod spec.
+	// +optional
+	Sidecar *SidecarConfig `json:"sidecar,omitempty"`
+
+	// The keyspaces to back up. All the keyspaces are backed up when empty.
+	// +optional
+	Keyspaces []string `json:"keyspaces,omitempty"`
+
+	// The keyspaces that are not backed up.
+	// +optional
+	ExcludeKeyspaces []string `json:"excludeKeyspaces,omitempty"`
+
+	// Retries the backup of the nodes on which it failed, with the same backup name. Nodes
+	// are not retried when not set.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// The maximum duration of the whole backup, e.g. "6h". The nodes that have not finished
+	// the backup when it expires are marked as timed out and are not retried.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// The maximum duration of each attempt to back up a single node, e.g. "2h". A node that
+	// times out is retried according to the retry policy.
+	// +optional
+	NodeTimeout *metav1.Duration `json:"nodeTimeout,omitempty"`
+
+	// Cancels the backup while it is running. The nodes that have not finished the backup
+	// are marked as cancelled and their Backup requests are aborted. Deleting the
+	// CassandraBackup also cancels it.
+	// +optional
+	Cancel bool `json:"cancel,omitempty"`
+
+	// The maximum number of nodes that are backed up at the same time, including the nodes
+	// waiting to be retried. All the nodes are backed up at once when 0.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentNodes int32 `json:"maxConcurrentNodes,omitempty"`
+
+	// Backs up the racks one after the other, in the order of the racks of the
+	// CassandraDatacenter. The nodes of a rack are backed up once all the nodes of the
+	// previous rack are done, within the limit of maxConcurrentNodes.
+	// +optional
+	RackByRack bool `json:"rackByRack,omitempty"`
+
+	// Actions run before and after the backup.
+	// +optional
+	Hooks *BackupHooks `json:"hooks,omitempty"`
+}
+
+// BackupHooks are the actions run around a backup. The pre-backup hooks run in order before
+// the backup of any node starts. The post-backup hooks run in order once the backup of all
+// the nodes is done, whether it succeeded or not.
+type BackupHooks struct {
+	// +optional
+	Pre []BackupHook `json:"pre,omitempty"`
+
+	// +optional
+	Post []BackupHook `json:"post,omitempty"`
+}
+
+// BackupHookLabel is set on the Jobs created for hooks. Its value is the name of the
+// CassandraBackup.
+const BackupHookLabel = "cassandra.k8ssandra.io/backup-hook"
+
+// HookStage tells whether a hook runs before or after the backup.
+type HookStage string
+
+const (
+	PreBackupHook  HookStage = "Pre"
+	PostBackupHook HookStage = "Post"
+)
+
+// HookFailurePolicy describes how the failure of a hook affects the backup.
+type HookFailurePolicy string
+
+const (
+	// FailBackupOnHookFailure fails the backup. A pre-backup hook that fails prevents the
+	// backup from starting, but the post-backup hooks still run.
+	FailBackupOnHookFailure HookFailurePolicy = "Fail"
+
+	// IgnoreHookFailure only records the failure in the status.
+	IgnoreHookFailure HookFailurePolicy = "Ignore"
+)
+
+// BackupHook is an action run before or after a backup. Exactly one of exec and job must
+// be set.
+type BackupHook struct {
+	// The name of the hook, unique among the hooks of the same stage.
+	Name string `json:"name"`
+
+	// Runs a command in a container of each Cassandra pod of the datacenter.
+	// +optional
+	Exec *ExecHook `json:"exec,omitempty"`
+
+	// Runs a Job in the namespace of the backup.
+	// +optional
+	Job *JobHook `json:"job,omitempty"`
+
+	// How the failure of the hook affects the backup: "Fail" or "Ignore".
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +kubebuilder:default:=Fail
+	// +optional
+	FailurePolicy HookFailurePolicy `json:"failurePolicy,omitempty"`
+
+	// How long the hook may run, e.g. "10m". Defaults to 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ExecHook runs a command in a container of each Cassandra pod through the Kubernetes API.
+type ExecHook struct {
+	// The container in which the command runs. Defaults to "cassandra".
+	// +optional
+	Container string `json:"container,omitempty"`
+
+	// The command and its arguments. It is not run in a shell.
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+}
+
+// JobHook runs a Job and waits for it to complete.
+type JobHook struct {
+	// The template of the Job. Its active deadline defaults to the timeout of the hook. It
+	// is validated when the Job is created.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template batchv1.JobTemplateSpec `json:"template"`
+}
+
+// RetryPolicy describes how the backup of a node is retried after a failure.
+type RetryPolicy struct {
+	// The maximum number of times the backup of a node is attempted, including the first
+	// attempt.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=3
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// How long to wait before the first retry, e.g. "30s". The wait doubles with each
+	// subsequent retry. Defaults to 30s.
+	// +optional
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+
+	// The maximum time to wait between two attempts. Defaults to 10m.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// SidecarConfig describes how to connect to the Medusa sidecars.
+type SidecarConfig struct {
+	// The name of the sidecar container.
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+
+	// The port of the gRPC server of the sidecar.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// NodeBackupState is the state of the backup of a single Cassandra node.
+type NodeBackupState string
+
+const (
+	NodeBackupPending    NodeBackupState = "Pending"
+	NodeBackupInProgress NodeBackupState = "InProgress"
+	NodeBackupFinished   NodeBackupState = "Finished"
+	NodeBackupFailed     NodeBackupState = "Failed"
+	NodeBackupTimedOut   NodeBackupState = "TimedOut"
+	NodeBackupCancelled  NodeBackupState = "Cancelled"
+)
+
+// NodeBackupStatus is the progress of the backup of a single Cassandra node.
+type NodeBackupStatus struct {
+	// The name of the pod of the node.
+	Pod string `json:"pod"`
+
+	// The IP address of the pod when the backup started.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// The rack of the node.
+	// +optional
+	Rack string `json:"rack,omitempty"`
+
+	// The state of the backup of the node: "Pending", "InProgress", "Finished", "Failed",
+	// "TimedOut" or "Cancelled". The nodes in the last three states are listed as failed.
+	State NodeBackupState `json:"state"`
+
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	// The time at which the operator observed that the backup of the node finished or
+	// failed.
+	FinishTime metav1.Time `json:"finishTime,omitempty"`
+
+	// The number of times the backup has been requested from the sidecar of the node.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// The last error returned by the sidecar, or the reason why the backup of the node
+	// failed.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// BackupSummary is the inventory of a backup in storage. A backup spans all the
+// datacenters that were backed up under the same name, so the counts and nodes are those
+// of all of them.
+type BackupSummary struct {
+	// The number of nodes that are part of the backup.
+	TotalNodes int32 `json:"totalNodes"`
+
+	// The number of nodes whose backup is complete.
+	FinishedNodes int32 `json:"finishedNodes"`
+
+	// The time at which the backup started, as recorded in storage.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	// The time at which the backup of the last node finished, as recorded in storage. It
+	// is only set when the backup is complete.
+	// +optional
+	FinishTime metav1.Time `json:"finishTime,omitempty"`
+
+	// The nodes that are part of the backup.
+	// +optional
+	Nodes []BackupNode `json:"nodes,omitempty"`
+}
+
+// BackupNode describes a node that is part of a backup in storage.
+type BackupNode struct {
+	// The address of the node.
+	Host string `json:"host"`
+
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// +optional
+	Rack string `json:"rack,omitempty"`
+
+	// The tokens owned by the node when it was backed up.
+	// +optional
+	Tokens []int64 `json:"tokens,omitempty"`
+}
+
+// HookState is the state of a hook.
+type HookState string
+
+const (
+	HookRunning   HookState = "Running"
+	HookSucceeded HookState = "Succeeded"
+	HookFailed    HookState = "Failed"
+)
+
+// BackupHookStatus is the outcome of a hook.
+type BackupHookStatus struct {
+	Name string `json:"name"`
+
+	Stage HookStage `json:"stage"`
+
+	// The state of the hook: "Running", "Succeeded" or "Failed".
+	State HookState `json:"state"`
+
+	// The reason why the hook failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	FinishTime metav1.Time `json:"finishTime,omitempty"`
 }
 
 type CassandraDatacenterTemplateSpec struct {
@@ -61,17 +395,64 @@ type CassandraBackupStatus struct {
 
 	StartTime metav1.Time `json:"startTime,omitempty"`
 
+	// The time at which the backup finished. It is the finish time recorded in storage
+	// when the backup is complete there, otherwise the time at which the operator observed
+	// that the backup was done.
 	FinishTime metav1.Time `json:"finishTime,omitempty"`
 
+	// The inventory of the backup in storage, as reported by the sidecars once the backup
+	// is done.
+	// +optional
+	Summary *BackupSummary `json:"summary,omitempty"`
+
+	// The pods that wait for their turn to be backed up, when the number of concurrent
+	// nodes is limited or when the racks are backed up one after the other.
+	// +optional
+	Pending []string `json:"pending,omitempty"`
+
 	InProgress []string `json:"inProgress,omitempty"`
 
 	Finished []string `json:"finished,omitempty"`
 
 	Failed []string `json:"failed,omitempty"`
+
+	// The progress of the backup of each node.
+	// +optional
+	// +listType=map
+	// +listMapKey=pod
+	Nodes []NodeBackupStatus `json:"nodes,omitempty"`
+
+	// The keyspaces that have been backed up. All the keyspaces have been backed up when
+	// empty. Restores of keyspaces that are not in the backup are rejected.
+	// +optional
+	Keyspaces []string `json:"keyspaces,omitempty"`
+
+	// The keyspaces that have not been backed up.
+	// +optional
+	ExcludeKeyspaces []string `json:"excludeKeyspaces,omitempty"`
+
+	// The outcome of the hooks that have been run.
+	// +optional
+	Hooks []BackupHookStatus `json:"hooks,omitempty"`
+
+	// A summary of the conditions: "Pending", "Running", "Succeeded" or "Failed".
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Datacenter",type=string,JSONPath=`.spec.cassandraDatacenter`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.backupType`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Started",type=date,JSONPath=`.status.startTime`
+// +kubebuilder:printcolumn:name="Finished",type=date,JSONPath=`.status.finishTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // CassandraBackup is the Schema for the cassandrabackups API
 type CassandraBackup struct {
@@ -91,6 +472,44 @@ type CassandraBackupList struct {
 	Items           []CassandraBackup `json:"items"`
 }
 
+// IsSucceeded returns true if the backup finished on all pods.
+func (in *CassandraBackup) IsSucceeded() bool {
+	return meta.IsStatusConditionTrue(in.Status.Conditions, BackupConditionSucceeded)
+}
+
+// IsFailed returns true if the backup finished and failed on at least one pod.
+func (in *CassandraBackup) IsFailed() bool {
+	return meta.IsStatusConditionTrue(in.Status.Conditions, BackupConditionFailed)
+}
+
+// IsImported returns true if the backup was imported from storage by a
+// CassandraBackupSync rather than taken by the operator.
+func (in *CassandraBackup) IsImported() bool {
+	_, found := in.Labels[ImportedBackupLabel]
+	return found
+}
+
+// GetNode returns the status of the backup of the node running in pod, or nil if the pod
+// is not part of the backup.
+func (in *CassandraBackupStatus) GetNode(pod string) *NodeBackupStatus {
+	for i := range in.Nodes {
+		if in.Nodes[i].Pod == pod {
+			return &in.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// GetHook returns the status of the hook of the given stage, or nil if it has not been run.
+func (in *CassandraBackupStatus) GetHook(stage HookStage, name string) *BackupHookStatus {
+	for i := range in.Hooks {
+		if in.Hooks[i].Stage == stage && in.Hooks[i].Name == name {
+			return &in.Hooks[i]
+		}
+	}
+	return nil
+}
+
 func init() {
 	SchemeBuilder.Register(&CassandraBackup{}, &CassandraBackupList{})
 }
@@ -0,0 +1,260 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+)
+
+// log is for logging in this package.
+var cassandrabackuplog = logf.Log.WithName("cassandrabackup-resource")
+
+func (r *CassandraBackup) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete(); err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register("/validate-cassandra-k8ssandra-io-v1alpha1-cassandrabackup",
+		&webhook.Admission{Handler: &CassandraBackupValidator{Client: mgr.GetClient()}})
+	return nil
+}
+
+// +kubebuilder:webhook:path=/mutate-cassandra-k8ssandra-io-v1alpha1-cassandrabackup,mutating=true,failurePolicy=fail,sideEffects=None,groups=cassandra.k8ssandra.io,resources=cassandrabackups,verbs=create,versions=v1alpha1,name=mcassandrabackup.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &CassandraBackup{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *CassandraBackup) Default() {
+	cassandrabackuplog.Info("default", "name", r.Name)
+
+	if len(r.Spec.Type) == 0 {
+		r.Spec.Type = DifferentialBackup
+	}
+
+	if len(r.Spec.Name) == 0 {
+		name, err := GenerateBackupName(r, time.Now())
+		if err != nil {
+			// The controller generates the name when the backup is started.
+			cassandrabackuplog.Error(err, "failed to generate backup name", "name", r.Name)
+			return
+		}
+		r.Spec.Name = name
+		metav1.SetMetaDataAnnotation(&r.ObjectMeta, GeneratedBackupNameAnnotation, "true")
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-cassandra-k8ssandra-io-v1alpha1-cassandrabackup,mutating=false,failurePolicy=fail,sideEffects=None,groups=cassandra.k8ssandra.io,resources=cassandrabackups,verbs=create;update,versions=v1alpha1,name=vcassandrabackup.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// CassandraBackupValidator validates CassandraBackups. It uses Client to look up the
+// datacenter and the pods of the backups.
+// +kubebuilder:object:generate=false
+type CassandraBackupValidator struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &CassandraBackupValidator{}
+var _ admission.DecoderInjector = &CassandraBackupValidator{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (v *CassandraBackupValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *CassandraBackupValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	backup := &CassandraBackup{}
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := v.decoder.DecodeRaw(req.Object, backup); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		return validationResponse(v.ValidateCreate(ctx, backup))
+	case admissionv1.Update:
+		old := &CassandraBackup{}
+		if err := v.decoder.DecodeRaw(req.Object, backup); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		return validationResponse(v.ValidateUpdate(ctx, backup, old))
+	default:
+		return admission.Allowed("")
+	}
+}
+
+// ValidateCreate validates a CassandraBackup that is created.
+func (v *CassandraBackupValidator) ValidateCreate(ctx context.Context, r *CassandraBackup) error {
+	cassandrabackuplog.Info("validate create", "name", r.Name)
+
+	if errs := append(r.Spec.ValidateKeyspaces(), r.Spec.ValidateHooks()...); len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, errs)
+	}
+	if errs := r.validateImportedDeletionPolicy(); len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, errs)
+	}
+
+	// Imported backups may have been taken from a datacenter that no longer exists, or
+	// exists elsewhere.
+	if r.IsImported() {
+		return nil
+	}
+
+	return v.validateSidecar(ctx, r)
+}
+
+// ValidateUpdate validates a CassandraBackup that is updated from oldBackup.
+func (v *CassandraBackupValidator) ValidateUpdate(ctx context.Context, r, oldBackup *CassandraBackup) error {
+	cassandrabackuplog.Info("validate update", "name", r.Name)
+
+	if oldBackup.IsImported() {
+		if oldBackup.Labels[ImportedBackupLabel] != r.Labels[ImportedBackupLabel] {
+			return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, field.ErrorList{
+				field.Forbidden(field.NewPath("metadata", "labels").Key(ImportedBackupLabel), "the label of an imported backup cannot be changed"),
+			})
+		}
+		if errs := r.validateImportedDeletionPolicy(); len(errs) > 0 {
+			return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, errs)
+		}
+		oldSpec, newSpec := oldBackup.Spec.DeepCopy(), r.Spec.DeepCopy()
+		oldSpec.DeletionPolicy, newSpec.DeletionPolicy = "", ""
+		if !reflect.DeepEqual(oldSpec, newSpec) {
+			return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, field.ErrorList{
+				field.Forbidden(field.NewPath("spec"), "the spec of an imported backup cannot be changed"),
+			})
+		}
+		return nil
+	}
+
+	if oldBackup.Status.StartTime.IsZero() {
+		if errs := append(r.Spec.ValidateKeyspaces(), r.Spec.ValidateHooks()...); len(errs) > 0 {
+			return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, errs)
+		}
+		return nil
+	}
+
+	if oldBackup.Spec.Cancel && !r.Spec.Cancel {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "cancel"), "a backup that has been cancelled cannot be resumed"),
+		})
+	}
+
+	// The deletion policy only matters once the backup is deleted, so it can be changed
+	// at any time. A running backup can be cancelled.
+	oldSpec, newSpec := oldBackup.Spec.DeepCopy(), r.Spec.DeepCopy()
+	oldSpec.DeletionPolicy, newSpec.DeletionPolicy = "", ""
+	oldSpec.Cancel, newSpec.Cancel = false, false
+	if !reflect.DeepEqual(oldSpec, newSpec) {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("spec"), "the spec of a backup that has been started cannot be changed, except for deletionPolicy and cancel"),
+		})
+	}
+	return nil
+}
+
+// validateImportedDeletionPolicy checks that an imported backup is never deleted from
+// storage, as the backup in storage is shared by the CassandraBackups of all its datacenters.
+func (r *CassandraBackup) validateImportedDeletionPolicy() field.ErrorList {
+	if !r.IsImported() || r.Spec.DeletionPolicy != DeleteBackup {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(field.NewPath("spec", "deletionPolicy"), r.Spec.DeletionPolicy, []string{string(RetainBackup)})}
+}
+
+// validateSidecar checks that all pods of the datacenter run the Medusa sidecar. The
+// datacenter is not required to exist yet.
+func (v *CassandraBackupValidator) validateSidecar(ctx context.Context, r *CassandraBackup) error {
+	dc := &cassdcapi.CassandraDatacenter{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Spec.CassandraDatacenter}, dc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return apierrors.NewInternalError(err)
+	}
+
+	pods, err := cassandra.GetDatacenterPods(ctx, v.Client, dc)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// The datacenter has not been deployed yet.
+			return nil
+		}
+		return apierrors.NewInternalError(err)
+	}
+
+	containerName := r.sidecarContainerName(dc)
+	missing := make([]string, 0)
+	for _, pod := range pods {
+		if !cassandra.HasContainer(&pod, containerName) {
+			missing = append(missing, pod.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraBackup").GroupKind(), r.Name, field.ErrorList{
+			field.Invalid(field.NewPath("spec", "cassandraDatacenter"), r.Spec.CassandraDatacenter,
+				fmt.Sprintf("container %s not found in pods %s", containerName, strings.Join(missing, ", "))),
+		})
+	}
+	return nil
+}
+
+func (r *CassandraBackup) sidecarContainerName(dc *cassdcapi.CassandraDatacenter) string {
+	if r.Spec.Sidecar != nil && len(r.Spec.Sidecar.ContainerName) > 0 {
+		return r.Spec.Sidecar.ContainerName
+	}
+	if name, found := dc.Annotations[medusa.SidecarContainerAnnotation]; found {
+		return name
+	}
+	return medusa.DefaultSidecarContainerName
+}
+
+// validationResponse converts the error returned by a validation to an admission response.
+func validationResponse(err error) admission.Response {
+	if err == nil {
+		return admission.Allowed("")
+	}
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+	}
+	return admission.Denied(err.Error())
+}
@@ -0,0 +1,139 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupScheduleLabel is set on each CassandraBackup created by a
+	// CassandraBackupSchedule. Its value is the name of the schedule.
+	BackupScheduleLabel = "cassandra.k8ssandra.io/backup-schedule"
+)
+
+// ConcurrencyPolicy describes how a new scheduled backup is handled when backups from
+// the same schedule are still running.
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows scheduled backups to run concurrently.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+
+	// ForbidConcurrent skips the next scheduled backup if the previous one has not
+	// finished yet.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+
+	// ReplaceConcurrent deletes the running backup and replaces it with a new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// CassandraBackupScheduleSpec defines the desired state of CassandraBackupSchedule
+type CassandraBackupScheduleSpec struct {
+	// The schedule in Cron format, see https://en.wikipedia.org/wiki/Cron.
+	CronSchedule string `json:"cronSchedule"`
+
+	// Specifies how to treat concurrent executions of a backup: "Allow", "Forbid" or
+	// "Replace".
+	// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+	// +kubebuilder:default:=Allow
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// The template of the CassandraBackup objects created by the schedule. The name of the
+	// backup must be left empty: it is generated for each scheduled backup from the backup
+	// name template of the operator, using the scheduled time as the timestamp.
+	BackupSpec CassandraBackupSpec `json:"backupSpec"`
+
+	// Determines which of the backups created by this schedule are kept. Expired backups
+	// are deleted along with their data in the Medusa storage backend. When not set,
+	// backups are kept forever.
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// RetentionPolicy describes which backups to keep. The count-based rules follow the
+// grandfather-father-son scheme: a backup is kept when any of them selects it. When none
+// of them is set, only MaxAge applies. Failed backups are not counted and are deleted once
+// a more recent backup succeeded.
+type RetentionPolicy struct {
+	// The number of most recent backups to keep.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepLast int32 `json:"keepLast,omitempty"`
+
+	// The number of days for which to keep the most recent backup of the day.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepDaily int32 `json:"keepDaily,omitempty"`
+
+	// The number of weeks for which to keep the most recent backup of the week.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepWeekly int32 `json:"keepWeekly,omitempty"`
+
+	// The number of months for which to keep the most recent backup of the month.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepMonthly int32 `json:"keepMonthly,omitempty"`
+
+	// Backups older than this are deleted even if they are selected by one of the
+	// count-based rules, e.g. "720h".
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// CassandraBackupScheduleStatus defines the observed state of CassandraBackupSchedule
+type CassandraBackupScheduleStatus struct {
+	// The last time a backup was scheduled.
+	LastScheduleTime metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// The name of the most recent CassandraBackup created by this schedule that finished
+	// without any failed pods.
+	LastSuccessfulBackup string `json:"lastSuccessfulBackup,omitempty"`
+
+	// The names of the CassandraBackups created by this schedule that are still running.
+	Active []string `json:"active,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.cronSchedule`
+// +kubebuilder:printcolumn:name="Datacenter",type=string,JSONPath=`.spec.backupSpec.cassandraDatacenter`
+// +kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+
+// CassandraBackupSchedule is the Schema for the cassandrabackupschedules API
+type CassandraBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CassandraBackupScheduleSpec   `json:"spec,omitempty"`
+	Status CassandraBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CassandraBackupScheduleList contains a list of CassandraBackupSchedule
+type CassandraBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CassandraBackupSchedule{}, &CassandraBackupScheduleList{})
+}
@@ -0,0 +1,101 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ImportedBackupLabel is set on each CassandraBackup imported from storage by a
+	// CassandraBackupSync. Its value is the name of the sync.
+	ImportedBackupLabel = "cassandra.k8ssandra.io/imported-by"
+
+	// BackupSyncConditionSynced reports the outcome of the last synchronization.
+	BackupSyncConditionSynced = "Synced"
+)
+
+// CassandraBackupSyncSpec defines the desired state of CassandraBackupSync
+type CassandraBackupSyncSpec struct {
+	// The name of the CassandraDatacenter whose Medusa sidecars list the backups that
+	// exist in storage.
+	CassandraDatacenter string `json:"cassandraDatacenter"`
+
+	// Overrides how the Medusa sidecars are located in the pods of the datacenter.
+	// +optional
+	Sidecar *SidecarConfig `json:"sidecar,omitempty"`
+
+	// How often the backups are listed, e.g. "1h". Defaults to 10m.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Only the backups of these datacenters are imported. The backups of all the
+	// datacenters are imported when empty.
+	// +optional
+	Datacenters []string `json:"datacenters,omitempty"`
+
+	// Deletes the CassandraBackups imported by this sync whose backup no longer exists in
+	// storage.
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+}
+
+// CassandraBackupSyncStatus defines the observed state of CassandraBackupSync
+type CassandraBackupSyncStatus struct {
+	// The last time the backups were listed successfully.
+	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// The number of CassandraBackups imported by this sync.
+	// +optional
+	Imported int32 `json:"imported,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Datacenter",type=string,JSONPath=`.spec.cassandraDatacenter`
+// +kubebuilder:printcolumn:name="Imported",type=integer,JSONPath=`.status.imported`
+// +kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
+
+// CassandraBackupSync imports the backups that exist in the Medusa storage backend as
+// CassandraBackups, so that backups taken outside of the operator can be restored. The
+// imported CassandraBackups are labelled with ImportedBackupLabel and are read-only: their
+// status is maintained by the sync.
+type CassandraBackupSync struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CassandraBackupSyncSpec   `json:"spec,omitempty"`
+	Status CassandraBackupSyncStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CassandraBackupSyncList contains a list of CassandraBackupSync
+type CassandraBackupSyncList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraBackupSync `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CassandraBackupSync{}, &CassandraBackupSyncList{})
+}
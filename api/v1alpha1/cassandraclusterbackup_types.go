@@ -0,0 +1,182 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClusterBackupLabel is set on each CassandraBackup created by a
+	// CassandraClusterBackup. Its value is the name of the cluster backup.
+	ClusterBackupLabel = "cassandra.k8ssandra.io/cluster-backup"
+)
+
+// CassandraClusterBackupSpec defines the desired state of CassandraClusterBackup
+type CassandraClusterBackupSpec struct {
+	// The name of the backup, shared by the backups of all the datacenters. When empty, it
+	// is generated from the backup name template of the operator with the name of the
+	// cluster in place of the datacenter.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// The name of the Cassandra cluster to back up, as set in the clusterName property of
+	// its CassandraDatacenters.
+	CassandraCluster string `json:"cassandraCluster"`
+
+	// The names of the CassandraDatacenters to back up. All the datacenters of the cluster
+	// in the namespace are backed up when empty.
+	// +optional
+	Datacenters []string `json:"datacenters,omitempty"`
+
+	// The type of the backup: "full" or "differential"
+	// +kubebuilder:validation:Enum=differential;full;
+	// +kubebuilder:default:=differential
+	Type BackupType `json:"backupType,omitempty"`
+
+	// Overrides how the Medusa sidecars are located in the pods of the datacenters.
+	// +optional
+	Sidecar *SidecarConfig `json:"sidecar,omitempty"`
+
+	// The keyspaces to back up. All the keyspaces are backed up when empty.
+	// +optional
+	Keyspaces []string `json:"keyspaces,omitempty"`
+
+	// The keyspaces that are not backed up.
+	// +optional
+	ExcludeKeyspaces []string `json:"excludeKeyspaces,omitempty"`
+
+	// Retries the backup of the nodes on which it failed.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// The maximum duration of the backup of each datacenter.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// The maximum duration of each attempt to back up a single node.
+	// +optional
+	NodeTimeout *metav1.Duration `json:"nodeTimeout,omitempty"`
+
+	// Cancels the backups of all the datacenters while they are running.
+	// +optional
+	Cancel bool `json:"cancel,omitempty"`
+
+	// The maximum number of nodes of each datacenter that are backed up at the same time.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentNodes int32 `json:"maxConcurrentNodes,omitempty"`
+
+	// Backs up the racks of each datacenter one after the other.
+	// +optional
+	RackByRack bool `json:"rackByRack,omitempty"`
+
+	// Actions run before and after the backup of each datacenter.
+	// +optional
+	Hooks *BackupHooks `json:"hooks,omitempty"`
+}
+
+// DatacenterBackupStatus is the progress of the backup of a single datacenter.
+type DatacenterBackupStatus struct {
+	// The name of the CassandraDatacenter.
+	Name string `json:"name"`
+
+	// The name of the CassandraBackup that backs up the datacenter.
+	Backup string `json:"backup"`
+
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	FinishTime metav1.Time `json:"finishTime,omitempty"`
+
+	Pending []string `json:"pending,omitempty"`
+
+	InProgress []string `json:"inProgress,omitempty"`
+
+	Finished []string `json:"finished,omitempty"`
+
+	Failed []string `json:"failed,omitempty"`
+
+	CassdcTemplateSpec *CassandraDatacenterTemplateSpec `json:"cassdcTemplateSpec,omitempty"`
+}
+
+// CassandraClusterBackupStatus defines the observed state of CassandraClusterBackup
+type CassandraClusterBackupStatus struct {
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	// The time at which the backups of all the datacenters finished.
+	FinishTime metav1.Time `json:"finishTime,omitempty"`
+
+	// The progress of the backup of each datacenter. The datacenters are selected when
+	// the backup starts.
+	Datacenters []DatacenterBackupStatus `json:"datacenters,omitempty"`
+
+	// A summary of the conditions: "Pending", "Running", "Succeeded" or "Failed".
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.cassandraCluster`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.backupType`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Started",type=date,JSONPath=`.status.startTime`
+// +kubebuilder:printcolumn:name="Finished",type=date,JSONPath=`.status.finishTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CassandraClusterBackup is the Schema for the cassandraclusterbackups API. It backs up
+// every datacenter of a cluster under a single backup name by creating a CassandraBackup
+// for each of them.
+type CassandraClusterBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CassandraClusterBackupSpec   `json:"spec,omitempty"`
+	Status CassandraClusterBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CassandraClusterBackupList contains a list of CassandraClusterBackup
+type CassandraClusterBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraClusterBackup `json:"items"`
+}
+
+// IsSucceeded returns true if the backups of all the datacenters succeeded.
+func (in *CassandraClusterBackup) IsSucceeded() bool {
+	return meta.IsStatusConditionTrue(in.Status.Conditions, BackupConditionSucceeded)
+}
+
+// IsFailed returns true if the backups finished and at least one of them failed.
+func (in *CassandraClusterBackup) IsFailed() bool {
+	return meta.IsStatusConditionTrue(in.Status.Conditions, BackupConditionFailed)
+}
+
+func init() {
+	SchemeBuilder.Register(&CassandraClusterBackup{}, &CassandraClusterBackupList{})
+}
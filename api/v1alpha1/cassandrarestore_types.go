@@ -31,13 +31,98 @@ type CassandraDatacenterConfig struct {
 	ClusterName string `json:"clusterName"`
 }
 
+// RestorePhase is a summary of the conditions of a CassandraRestore.
+type RestorePhase string
+
+const (
+	RestorePhasePending   RestorePhase = "Pending"
+	RestorePhaseRunning   RestorePhase = "Running"
+	RestorePhaseSucceeded RestorePhase = "Succeeded"
+	RestorePhaseFailed    RestorePhase = "Failed"
+)
+
+const (
+	// RestoreConditionStarted is true once the restore operation has started.
+	RestoreConditionStarted = "Started"
+
+	// RestoreConditionDatacenterStopped is true once the datacenter has been stopped for
+	// the restore. It is only set when the restore requires a shutdown.
+	RestoreConditionDatacenterStopped = "DatacenterStopped"
+
+	// RestoreConditionDatacenterCreated is true once the new datacenter has been created
+	// for a remote restore.
+	RestoreConditionDatacenterCreated = "DatacenterCreated"
+
+	// RestoreConditionRestored is true once the backup has been restored and the
+	// datacenter is ready.
+	RestoreConditionRestored = "Restored"
+
+	// RestoreConditionFailed is true if the restore cannot proceed.
+	RestoreConditionFailed = "Failed"
+
+	// RestoreConditionTopologyCompatible tells whether the size, racks and number of tokens
+	// per node of the backup match the datacenter that is restored. The tokens that the nodes
+	// of the datacenter currently own are not compared with the tokens of the backup. It is
+	// unknown when the backup does not record its topology.
+	RestoreConditionTopologyCompatible = "TopologyCompatible"
+)
+
+// BackupSelector selects a successful CassandraBackup of a datacenter. Datacenter and
+// LabelSelector narrow down the candidate backups, and exactly one of Latest or Before
+// tells which of them is restored. The backup must also have finished before
+// RestorePointInTime if it is set.
+type BackupSelector struct {
+	// The datacenter from which the backup was taken. Defaults to the name of the
+	// CassandraDatacenter of the restore.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Selects the candidate backup that finished last.
+	// +optional
+	Latest bool `json:"latest,omitempty"`
+
+	// Selects the candidate backup that finished last before this time.
+	// +optional
+	Before *metav1.Time `json:"before,omitempty"`
+
+	// Only selects the backups whose labels match.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// CommitLogReplay configures the replay of the archived commit logs of a point in time
+// restore. It is written along with restore_point_in_time to the commit log archiving
+// properties of the restored datacenter, whose archive_command is kept. These properties are
+// removed once the restore is complete so that the commit logs are not replayed again, which
+// cass-operator rolls out with a rolling restart of the datacenter.
+type CommitLogReplay struct {
+	// The directories of the Cassandra container in which archive_command stores the
+	// archived commit log segments.
+	// +kubebuilder:validation:MinItems=1
+	RestoreDirectories []string `json:"restoreDirectories"`
+
+	// The command that copies an archived commit log segment, %from, to the commit log
+	// directory, %to. Defaults to "cp -f %from %to".
+	// +optional
+	RestoreCommand string `json:"restoreCommand,omitempty"`
+}
+
 // CassandraRestoreSpec defines the desired state of CassandraRestore
 type CassandraRestoreSpec struct {
-	// The name of the CassandraBackup to restore
-	Backup string `json:"backup"`
+	// The name of the CassandraBackup to restore. It can be omitted when BackupSelector or
+	// RestorePointInTime is set.
+	// +optional
+	Backup string `json:"backup,omitempty"`
+
+	// Selects the CassandraBackup to restore when the restore starts, instead of naming it
+	// in Backup.
+	// +optional
+	BackupSelector *BackupSelector `json:"backupSelector,omitempty"`
 
 	// When true the restore will be performed on the source cluster from which the backup
-	// was taken. There will be a rolling restart of the source cluster.
+	// was taken. There will be a rolling restart of the source cluster. When false, a new
+	// CassandraDatacenter is created from the backup with the name and cluster name given
+	// in CassandraDatacenter. It is owned by the CassandraRestore.
 	InPlace bool `json:"inPlace,omitEmpty"`
 
 	// When set to true, the cluster is shutdown before the restore is applied. This is necessary
@@ -45,6 +130,45 @@ type CassandraRestoreSpec struct {
 	Shutdown bool `json:"shutdown,omitEmpty"`
 
 	CassandraDatacenter CassandraDatacenterConfig `json:"cassandraDatacenter"`
+
+	// The keyspaces to restore. All the keyspaces of the backup are restored when both
+	// Keyspaces and Tables are empty.
+	// +optional
+	Keyspaces []string `json:"keyspaces,omitempty"`
+
+	// The tables to restore, in the keyspace.table format. They are restored in addition to
+	// the keyspaces listed in Keyspaces.
+	// +optional
+	Tables []string `json:"tables,omitempty"`
+
+	// The keyspaces that are not restored.
+	// +optional
+	ExcludeKeyspaces []string `json:"excludeKeyspaces,omitempty"`
+
+	// The tables that are not restored, in the keyspace.table format.
+	// +optional
+	ExcludeTables []string `json:"excludeTables,omitempty"`
+
+	// Restores the backup even if the size, racks or number of tokens per node of the
+	// datacenter do not match the backup. The tokens that the nodes of the datacenter own
+	// are not checked. The restored data may then be placed on the wrong nodes.
+	// +optional
+	AllowIncompatibleTopology bool `json:"allowIncompatibleTopology,omitempty"`
+
+	// Restores the datacenter to its state at this time. Unless Backup is set, the newest
+	// successful backup of the datacenter that finished before this time is restored. The
+	// archived commit logs are then replayed up to this time as configured by
+	// CommitLogReplay, which is required. Commit log archiving must be enabled in the
+	// datacenter of the backup with the archive_command of the commitlog-archiving-properties
+	// of its config.
+	// +optional
+	RestorePointInTime *metav1.Time `json:"restorePointInTime,omitempty"`
+
+	// Configures the replay of the archived commit logs of a point in time restore. It can
+	// only be set along with RestorePointInTime. The replay is removed from the datacenter
+	// config once the restore is complete, which restarts the datacenter.
+	// +optional
+	CommitLogReplay *CommitLogReplay `json:"commitLogReplay,omitempty"`
 }
 
 // CassandraRestoreStatus defines the observed state of CassandraRestore
@@ -58,15 +182,46 @@ type CassandraRestoreStatus struct {
 
 	DatacenterStopped metav1.Time `json:"datacenterStopped,omitempty"`
 
+	// The time at which the restore init container had been rolled out to all pods.
+	DatacenterUpdated metav1.Time `json:"datacenterUpdated,omitempty"`
+
+	// The time at which the new datacenter was created for a remote restore.
+	DatacenterCreated metav1.Time `json:"datacenterCreated,omitempty"`
+
+	// The name of the CassandraBackup that is restored. It is recorded when the restore
+	// starts, so that a backup chosen by BackupSelector or RestorePointInTime does not change
+	// afterwards.
+	// +optional
+	Backup string `json:"backup,omitempty"`
+
+	// The time up to which the archived commit logs are replayed for a point in time restore.
+	// +optional
+	ReplayHorizon metav1.Time `json:"replayHorizon,omitempty"`
+
 	InProgress []string `json:"inProgress,omitempty"`
 
 	Finished []string `json:"finished,omitempty"`
 
 	Failed []string `json:"failed,omitempty"`
+
+	// A summary of the conditions: "Pending", "Running", "Succeeded" or "Failed".
+	// +optional
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.status.backup`
+// +kubebuilder:printcolumn:name="Datacenter",type=string,JSONPath=`.spec.cassandraDatacenter.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Started",type=date,JSONPath=`.status.startTime`
+// +kubebuilder:printcolumn:name="Finished",type=date,JSONPath=`.status.finishTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // CassandraRestore is the Schema for the cassandrarestores API
 type CassandraRestore struct {
@@ -0,0 +1,156 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var cassandrarestorelog = logf.Log.WithName("cassandrarestore-resource")
+
+func (r *CassandraRestore) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register("/validate-cassandra-k8ssandra-io-v1alpha1-cassandrarestore",
+		&webhook.Admission{Handler: &CassandraRestoreValidator{Client: mgr.GetClient()}})
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-cassandra-k8ssandra-io-v1alpha1-cassandrarestore,mutating=false,failurePolicy=fail,sideEffects=None,groups=cassandra.k8ssandra.io,resources=cassandrarestores,verbs=create;update,versions=v1alpha1,name=vcassandrarestore.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// CassandraRestoreValidator validates CassandraRestores. It uses Client to look up the
+// backups that are restored.
+// +kubebuilder:object:generate=false
+type CassandraRestoreValidator struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &CassandraRestoreValidator{}
+var _ admission.DecoderInjector = &CassandraRestoreValidator{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (v *CassandraRestoreValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *CassandraRestoreValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	restore := &CassandraRestore{}
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := v.decoder.DecodeRaw(req.Object, restore); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		return validationResponse(v.ValidateCreate(ctx, restore))
+	case admissionv1.Update:
+		old := &CassandraRestore{}
+		if err := v.decoder.DecodeRaw(req.Object, restore); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		return validationResponse(v.ValidateUpdate(ctx, restore, old))
+	default:
+		return admission.Allowed("")
+	}
+}
+
+// ValidateCreate validates a CassandraRestore that is created.
+func (v *CassandraRestoreValidator) ValidateCreate(ctx context.Context, r *CassandraRestore) error {
+	cassandrarestorelog.Info("validate create", "name", r.Name)
+
+	errs := r.Spec.ValidateKeyspaces()
+	errs = append(errs, r.Spec.ValidateBackupSelector()...)
+	errs = append(errs, r.Spec.ValidatePointInTime()...)
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraRestore").GroupKind(), r.Name, errs)
+	}
+
+	if len(r.Spec.Backup) == 0 {
+		// The backup is chosen by the controller when the restore starts.
+		return nil
+	}
+	return v.validateBackup(ctx, r)
+}
+
+// ValidateUpdate validates a CassandraRestore that is updated from oldRestore.
+func (v *CassandraRestoreValidator) ValidateUpdate(ctx context.Context, r, oldRestore *CassandraRestore) error {
+	cassandrarestorelog.Info("validate update", "name", r.Name)
+
+	if reflect.DeepEqual(oldRestore.Spec, r.Spec) {
+		// Metadata updates, such as removing a finalizer, must not depend on the backup.
+		return nil
+	}
+
+	if oldRestore.Status.StartTime.IsZero() {
+		return v.ValidateCreate(ctx, r)
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("CassandraRestore").GroupKind(), r.Name, field.ErrorList{
+		field.Forbidden(field.NewPath("spec"), "the spec of a restore that has been started cannot be changed"),
+	})
+}
+
+// validateBackup checks that the backup exists, that it succeeded, that it contains the
+// keyspaces to restore, that it finished before the point in time to restore with commit log
+// archiving enabled and, for in place restores, that it was taken from the datacenter that is
+// restored.
+func (v *CassandraRestoreValidator) validateBackup(ctx context.Context, r *CassandraRestore) error {
+	backupPath := field.NewPath("spec", "backup")
+
+	backup := &CassandraBackup{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Spec.Backup}, backup); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.invalid(field.NotFound(backupPath, r.Spec.Backup))
+		}
+		return apierrors.NewInternalError(err)
+	}
+
+	if !backup.IsSucceeded() {
+		return r.invalid(field.Invalid(backupPath, r.Spec.Backup, "the backup has not succeeded"))
+	}
+
+	if r.Spec.InPlace && r.Spec.CassandraDatacenter.Name != backup.Spec.CassandraDatacenter {
+		return r.invalid(field.Invalid(field.NewPath("spec", "cassandraDatacenter", "name"), r.Spec.CassandraDatacenter.Name,
+			fmt.Sprintf("an in place restore must target the datacenter of the backup (%s)", backup.Spec.CassandraDatacenter)))
+	}
+
+	if errs := append(r.Spec.ValidateKeyspacesInBackup(backup), r.Spec.ValidatePointInTimeOfBackup(backup)...); len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CassandraRestore").GroupKind(), r.Name, errs)
+	}
+
+	return nil
+}
+
+func (r *CassandraRestore) invalid(err *field.Error) error {
+	return apierrors.NewInvalid(GroupVersion.WithKind("CassandraRestore").GroupKind(), r.Name, field.ErrorList{err})
+}
@@ -0,0 +1,59 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateHooks checks that the hook names are unique DNS labels within each stage and that
+// each hook sets exactly one action.
+func (s *CassandraBackupSpec) ValidateHooks() field.ErrorList {
+	if s.Hooks == nil {
+		return nil
+	}
+	hooksPath := field.NewPath("spec", "hooks")
+	errs := validateHooks(hooksPath.Child("pre"), s.Hooks.Pre)
+	return append(errs, validateHooks(hooksPath.Child("post"), s.Hooks.Post)...)
+}
+
+func validateHooks(path *field.Path, hooks []BackupHook) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]bool, len(hooks))
+	for i, hook := range hooks {
+		hookPath := path.Index(i)
+		if msgs := validation.IsDNS1123Label(hook.Name); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(hookPath.Child("name"), hook.Name, strings.Join(msgs, ", ")))
+		} else if names[hook.Name] {
+			errs = append(errs, field.Duplicate(hookPath.Child("name"), hook.Name))
+		}
+		names[hook.Name] = true
+
+		switch {
+		case hook.Exec == nil && hook.Job == nil:
+			errs = append(errs, field.Required(hookPath, "one of exec and job must be set"))
+		case hook.Exec != nil && hook.Job != nil:
+			errs = append(errs, field.Forbidden(hookPath, "only one of exec and job may be set"))
+		case hook.Exec != nil && len(hook.Exec.Command) == 0:
+			errs = append(errs, field.Required(hookPath.Child("exec", "command"), ""))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,140 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Cassandra keyspace and table names are made of up to 48 alphanumeric characters and
+// underscores.
+var cqlNameRegexp = regexp.MustCompile(`^\w{1,48}$`)
+
+// ValidateKeyspaces checks that the keyspaces to back up are valid names and that the
+// include and exclude lists do not overlap.
+func (s *CassandraBackupSpec) ValidateKeyspaces() field.ErrorList {
+	specPath := field.NewPath("spec")
+	errs := validateKeyspaceNames(specPath.Child("keyspaces"), s.Keyspaces)
+	errs = append(errs, validateKeyspaceNames(specPath.Child("excludeKeyspaces"), s.ExcludeKeyspaces)...)
+
+	excludedKeyspaces := toSet(s.ExcludeKeyspaces)
+	for i, keyspace := range s.Keyspaces {
+		if excludedKeyspaces[keyspace] {
+			errs = append(errs, field.Invalid(specPath.Child("keyspaces").Index(i), keyspace, "the keyspace is also excluded"))
+		}
+	}
+
+	return errs
+}
+
+// ContainsKeyspace returns true if the keyspace has been backed up according to the keyspaces
+// recorded in the status.
+func (in *CassandraBackup) ContainsKeyspace(keyspace string) bool {
+	if toSet(in.Status.ExcludeKeyspaces)[keyspace] {
+		return false
+	}
+	return len(in.Status.Keyspaces) == 0 || toSet(in.Status.Keyspaces)[keyspace]
+}
+
+// ValidateKeyspaces checks that the keyspaces and tables to restore are valid names and that
+// the include and exclude lists are consistent with each other.
+func (s *CassandraRestoreSpec) ValidateKeyspaces() field.ErrorList {
+	specPath := field.NewPath("spec")
+	errs := validateKeyspaceNames(specPath.Child("keyspaces"), s.Keyspaces)
+	errs = append(errs, validateTableNames(specPath.Child("tables"), s.Tables)...)
+	errs = append(errs, validateKeyspaceNames(specPath.Child("excludeKeyspaces"), s.ExcludeKeyspaces)...)
+	errs = append(errs, validateTableNames(specPath.Child("excludeTables"), s.ExcludeTables)...)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	excludedKeyspaces := toSet(s.ExcludeKeyspaces)
+	for i, keyspace := range s.Keyspaces {
+		if excludedKeyspaces[keyspace] {
+			errs = append(errs, field.Invalid(specPath.Child("keyspaces").Index(i), keyspace, "the keyspace is also excluded"))
+		}
+	}
+
+	excludedTables := toSet(s.ExcludeTables)
+	for i, table := range s.Tables {
+		if excludedTables[table] {
+			errs = append(errs, field.Invalid(specPath.Child("tables").Index(i), table, "the table is also excluded"))
+		} else if excludedKeyspaces[tableKeyspace(table)] {
+			errs = append(errs, field.Invalid(specPath.Child("tables").Index(i), table, "the keyspace of the table is excluded"))
+		}
+	}
+
+	return errs
+}
+
+// ValidateKeyspacesInBackup checks that the keyspaces and tables to restore are in the
+// backup.
+func (s *CassandraRestoreSpec) ValidateKeyspacesInBackup(backup *CassandraBackup) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	for i, keyspace := range s.Keyspaces {
+		if !backup.ContainsKeyspace(keyspace) {
+			errs = append(errs, field.Invalid(specPath.Child("keyspaces").Index(i), keyspace,
+				fmt.Sprintf("the keyspace is not in backup %s", backup.Name)))
+		}
+	}
+	for i, table := range s.Tables {
+		if !backup.ContainsKeyspace(tableKeyspace(table)) {
+			errs = append(errs, field.Invalid(specPath.Child("tables").Index(i), table,
+				fmt.Sprintf("the keyspace of the table is not in backup %s", backup.Name)))
+		}
+	}
+	return errs
+}
+
+func validateKeyspaceNames(path *field.Path, keyspaces []string) field.ErrorList {
+	var errs field.ErrorList
+	for i, keyspace := range keyspaces {
+		if !cqlNameRegexp.MatchString(keyspace) {
+			errs = append(errs, field.Invalid(path.Index(i), keyspace, "must be a valid keyspace name"))
+		}
+	}
+	return errs
+}
+
+func validateTableNames(path *field.Path, tables []string) field.ErrorList {
+	var errs field.ErrorList
+	for i, table := range tables {
+		parts := strings.Split(table, ".")
+		if len(parts) != 2 || !cqlNameRegexp.MatchString(parts[0]) || !cqlNameRegexp.MatchString(parts[1]) {
+			errs = append(errs, field.Invalid(path.Index(i), table, "must be a valid table name in the keyspace.table format"))
+		}
+	}
+	return errs
+}
+
+// tableKeyspace returns the keyspace of a table in the keyspace.table format.
+func tableKeyspace(table string) string {
+	return strings.SplitN(table, ".", 2)[0]
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
@@ -0,0 +1,89 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// CommitLogArchivingConfigKey is the key of the CassandraDatacenter config that holds
+	// the properties of commitlog_archiving.properties.
+	CommitLogArchivingConfigKey = "commitlog-archiving-properties"
+
+	// DefaultCommitLogRestoreCommand is the restore_command of point in time restores that
+	// do not set one.
+	DefaultCommitLogRestoreCommand = "cp -f %from %to"
+)
+
+// ValidatePointInTime checks that the replay of the archived commit logs is configured for
+// point in time restores, and only for them.
+func (s *CassandraRestoreSpec) ValidatePointInTime() field.ErrorList {
+	replayPath := field.NewPath("spec", "commitLogReplay")
+	switch {
+	case s.RestorePointInTime != nil && s.CommitLogReplay == nil:
+		return field.ErrorList{field.Required(replayPath, "the replay of the archived commit logs must be configured for a point in time restore")}
+	case s.RestorePointInTime == nil && s.CommitLogReplay != nil:
+		return field.ErrorList{field.Forbidden(replayPath, "the archived commit logs are only replayed for a point in time restore")}
+	case s.CommitLogReplay != nil && len(s.CommitLogReplay.RestoreDirectories) == 0:
+		return field.ErrorList{field.Required(replayPath.Child("restoreDirectories"), "the directories of the archived commit logs must be set")}
+	}
+	return nil
+}
+
+// ValidatePointInTimeOfBackup checks that the backup finished before the point in time
+// to restore, and that commit log archiving was enabled in its datacenter when it was taken.
+func (s *CassandraRestoreSpec) ValidatePointInTimeOfBackup(backup *CassandraBackup) field.ErrorList {
+	if s.RestorePointInTime == nil {
+		return nil
+	}
+	pointInTimePath := field.NewPath("spec", "restorePointInTime")
+	pointInTime := s.RestorePointInTime.UTC().Format(time.RFC3339)
+	if !backup.Status.FinishTime.Before(s.RestorePointInTime) {
+		return field.ErrorList{field.Invalid(pointInTimePath, pointInTime,
+			fmt.Sprintf("backup %s did not finish before the point in time", backup.Name))}
+	}
+	if backup.Status.CassdcTemplateSpec == nil || !CommitLogArchivingEnabled(&backup.Status.CassdcTemplateSpec.Spec) {
+		return field.ErrorList{field.Invalid(pointInTimePath, pointInTime,
+			fmt.Sprintf("commit log archiving was not enabled in the datacenter of backup %s", backup.Name))}
+	}
+	return nil
+}
+
+// CommitLogArchivingEnabled returns true if archive_command is set in the commit log
+// archiving properties of the datacenter config.
+func CommitLogArchivingEnabled(spec *cassdcapi.CassandraDatacenterSpec) bool {
+	if len(spec.Config) == 0 {
+		return false
+	}
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(spec.Config, &config); err != nil {
+		return false
+	}
+	var properties struct {
+		ArchiveCommand string `json:"archive_command"`
+	}
+	if err := json.Unmarshal(config[CommitLogArchivingConfigKey], &properties); err != nil {
+		return false
+	}
+	return len(properties.ArchiveCommand) > 0
+}
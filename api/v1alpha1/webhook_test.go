@@ -0,0 +1,525 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, cassdcapi.AddToScheme(scheme))
+	require.NoError(t, AddToScheme(scheme))
+	return scheme
+}
+
+func newWebhookClient(t *testing.T, objects ...client.Object) client.Reader {
+	return fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+}
+
+func newTestDatacenter() *cassdcapi.CassandraDatacenter {
+	return &cassdcapi.CassandraDatacenter{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dc1"},
+		Spec:       cassdcapi.CassandraDatacenterSpec{ClusterName: "cluster1"},
+	}
+}
+
+// newTestService returns the all pods service of the datacenter, which selects its pods.
+func newTestService(dc *cassdcapi.CassandraDatacenter) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: dc.Namespace, Name: dc.GetAllPodsServiceName()},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				cassdcapi.ClusterLabel:    dc.Spec.ClusterName,
+				cassdcapi.DatacenterLabel: dc.Name,
+			},
+		},
+	}
+}
+
+func newTestPod(name, containerName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels: map[string]string{
+				cassdcapi.ClusterLabel:    "cluster1",
+				cassdcapi.DatacenterLabel: "dc1",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "cassandra"}, {Name: containerName}},
+		},
+	}
+}
+
+func TestValidateBackupCreate(t *testing.T) {
+	ctx := context.Background()
+	dc := newTestDatacenter()
+	svc := newTestService(dc)
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+	}
+
+	v := &CassandraBackupValidator{Client: newWebhookClient(t, dc, svc, newTestPod("dc1-0", "medusa"), newTestPod("dc1-1", "medusa"))}
+	assert.NoError(t, v.ValidateCreate(ctx, backup))
+
+	v = &CassandraBackupValidator{Client: newWebhookClient(t, dc, svc, newTestPod("dc1-0", "medusa"), newTestPod("dc1-1", "backup"))}
+	err := v.ValidateCreate(ctx, backup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dc1-1")
+
+	overridden := backup.DeepCopy()
+	overridden.Spec.Sidecar = &SidecarConfig{ContainerName: "backup"}
+	v = &CassandraBackupValidator{Client: newWebhookClient(t, dc, svc, newTestPod("dc1-0", "backup"))}
+	assert.NoError(t, v.ValidateCreate(ctx, overridden))
+
+	other := newTestPod("dc1-0", "backup")
+	other.Namespace = "other"
+	v = &CassandraBackupValidator{Client: newWebhookClient(t, dc, svc, newTestPod("dc1-0", "medusa"), other)}
+	assert.NoError(t, v.ValidateCreate(ctx, backup), "only the pods selected by the all pods service are checked")
+
+	v = &CassandraBackupValidator{Client: newWebhookClient(t, dc, newTestPod("dc1-0", "backup"))}
+	assert.NoError(t, v.ValidateCreate(ctx, backup), "the datacenter does not have to be deployed")
+
+	v = &CassandraBackupValidator{Client: newWebhookClient(t)}
+	assert.NoError(t, v.ValidateCreate(ctx, backup), "the datacenter does not have to exist")
+
+	imported := backup.DeepCopy()
+	imported.Labels = map[string]string{ImportedBackupLabel: "sync"}
+	v = &CassandraBackupValidator{Client: newWebhookClient(t, dc, svc, newTestPod("dc1-0", "backup"))}
+	assert.NoError(t, v.ValidateCreate(ctx, imported), "the sidecars of imported backups are not checked")
+}
+
+func TestHandleBackupValidation(t *testing.T) {
+	decoder, err := admission.NewDecoder(newTestScheme(t))
+	require.NoError(t, err)
+
+	dc := newTestDatacenter()
+	v := &CassandraBackupValidator{Client: newWebhookClient(t, dc, newTestService(dc), newTestPod("dc1-0", "backup"))}
+	require.NoError(t, v.InjectDecoder(decoder))
+
+	newRequest := func(backup *CassandraBackup) admission.Request {
+		raw, err := json.Marshal(backup)
+		require.NoError(t, err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+	}
+	response := v.Handle(context.Background(), newRequest(backup))
+	assert.False(t, response.Allowed)
+	require.NotNil(t, response.Result)
+	assert.Contains(t, response.Result.Message, "dc1-0")
+
+	backup.Spec.Sidecar = &SidecarConfig{ContainerName: "backup"}
+	response = v.Handle(context.Background(), newRequest(backup))
+	assert.True(t, response.Allowed)
+}
+
+func TestDefaultBackup(t *testing.T) {
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+	}
+	backup.Default()
+	assert.Equal(t, DifferentialBackup, backup.Spec.Type)
+	assert.Regexp(t, "^dc1-differential-[0-9]{14}$", backup.Spec.Name)
+	assert.True(t, metav1.HasAnnotation(backup.ObjectMeta, GeneratedBackupNameAnnotation))
+
+	named := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{Name: "my-backup", CassandraDatacenter: "dc1", Type: FullBackup},
+	}
+	named.Default()
+	assert.Equal(t, "my-backup", named.Spec.Name)
+	assert.False(t, metav1.HasAnnotation(named.ObjectMeta, GeneratedBackupNameAnnotation))
+}
+
+func TestGenerateBackupName(t *testing.T) {
+	defer SetBackupNameTemplate(DefaultBackupNameTemplate)
+
+	created := time.Date(2021, 11, 15, 10, 30, 0, 0, time.UTC)
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "prod", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1", Type: FullBackup},
+	}
+
+	name, err := GenerateBackupName(backup, created)
+	require.NoError(t, err)
+	assert.Equal(t, "dc1-full-20211115103000", name)
+
+	require.NoError(t, SetBackupNameTemplate("{{.Namespace}}.{{.Datacenter}}.{{.Timestamp}}"))
+	name, err = GenerateBackupName(backup, created)
+	require.NoError(t, err)
+	assert.Equal(t, "prod.dc1.20211115103000", name)
+
+	assert.Error(t, SetBackupNameTemplate("{{.Cluster}}"), "unknown fields are rejected")
+	assert.Error(t, SetBackupNameTemplate("{{.Datacenter"))
+}
+
+func TestValidateBackupUpdate(t *testing.T) {
+	ctx := context.Background()
+	v := &CassandraBackupValidator{Client: newWebhookClient(t)}
+
+	old := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1", Type: FullBackup},
+	}
+
+	updated := old.DeepCopy()
+	updated.Spec.Type = DifferentialBackup
+	assert.NoError(t, v.ValidateUpdate(ctx, updated, old), "a backup that has not started can be changed")
+
+	old.Status.StartTime = metav1.Now()
+	assert.Error(t, v.ValidateUpdate(ctx, updated, old))
+
+	updated = old.DeepCopy()
+	updated.Spec.DeletionPolicy = DeleteBackup
+	assert.NoError(t, v.ValidateUpdate(ctx, updated, old), "the deletion policy can always be changed")
+
+	updated = old.DeepCopy()
+	updated.Spec.Cancel = true
+	assert.NoError(t, v.ValidateUpdate(ctx, updated, old), "a running backup can be cancelled")
+	assert.Error(t, v.ValidateUpdate(ctx, old, updated), "a cancelled backup cannot be resumed")
+
+	imported := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "imported", Labels: map[string]string{ImportedBackupLabel: "sync"}},
+		Spec:       CassandraBackupSpec{Name: "backup", CassandraDatacenter: "dc1"},
+	}
+
+	updated = imported.DeepCopy()
+	updated.Spec.Keyspaces = []string{"ks1"}
+	assert.Error(t, v.ValidateUpdate(ctx, updated, imported), "imported backups are read-only")
+
+	updated = imported.DeepCopy()
+	updated.Spec.DeletionPolicy = DeleteBackup
+	assert.Error(t, v.ValidateUpdate(ctx, updated, imported), "imported backups are never deleted from storage")
+	assert.Error(t, v.ValidateCreate(ctx, updated), "imported backups are never deleted from storage")
+
+	updated = imported.DeepCopy()
+	delete(updated.Labels, ImportedBackupLabel)
+	assert.Error(t, v.ValidateUpdate(ctx, updated, imported), "imported backups cannot be turned into regular backups")
+}
+
+func TestValidateRestoreCreate(t *testing.T) {
+	finished := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "finished"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+		Status: CassandraBackupStatus{
+			StartTime:  metav1.Now(),
+			FinishTime: metav1.Now(),
+			Conditions: []metav1.Condition{{Type: BackupConditionSucceeded, Status: metav1.ConditionTrue}},
+			CassdcTemplateSpec: &CassandraDatacenterTemplateSpec{
+				Spec: cassdcapi.CassandraDatacenterSpec{Config: newCommitLogArchivingConfig()},
+			},
+		},
+	}
+	notArchived := finished.DeepCopy()
+	notArchived.Name = "not-archived"
+	notArchived.Status.CassdcTemplateSpec = nil
+	running := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "running"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+		Status:     CassandraBackupStatus{StartTime: metav1.Now()},
+	}
+	failed := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "failed"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+		Status: CassandraBackupStatus{
+			StartTime:  metav1.Now(),
+			FinishTime: metav1.Now(),
+			Conditions: []metav1.Condition{{Type: BackupConditionFailed, Status: metav1.ConditionTrue}},
+		},
+	}
+	ctx := context.Background()
+	v := &CassandraRestoreValidator{Client: newWebhookClient(t, finished, notArchived, running, failed)}
+
+	newRestore := func(backup, dc string, inPlace bool) *CassandraRestore {
+		return &CassandraRestore{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "restore"},
+			Spec: CassandraRestoreSpec{
+				Backup:              backup,
+				InPlace:             inPlace,
+				CassandraDatacenter: CassandraDatacenterConfig{Name: dc},
+			},
+		}
+	}
+
+	assert.NoError(t, v.ValidateCreate(ctx, newRestore("finished", "dc1", true)))
+	assert.NoError(t, v.ValidateCreate(ctx, newRestore("finished", "dc2", false)))
+	assert.Error(t, v.ValidateCreate(ctx, newRestore("missing", "dc1", true)))
+	assert.Error(t, v.ValidateCreate(ctx, newRestore("running", "dc1", true)))
+	assert.Error(t, v.ValidateCreate(ctx, newRestore("failed", "dc1", true)), "the backup must have succeeded")
+	assert.Error(t, v.ValidateCreate(ctx, newRestore("finished", "dc2", true)))
+
+	pointInTime := metav1.NewTime(time.Now().Add(time.Hour))
+	restore := newRestore("", "dc1", true)
+	restore.Spec.RestorePointInTime = &pointInTime
+	assert.Error(t, v.ValidateCreate(ctx, restore), "the replay of the commit logs is required")
+	restore.Spec.CommitLogReplay = &CommitLogReplay{RestoreDirectories: []string{"/var/lib/cassandra/archive"}}
+	assert.NoError(t, v.ValidateCreate(ctx, restore), "the backup is chosen by the controller")
+	restore.Spec.Backup = "not-archived"
+	assert.Error(t, v.ValidateCreate(ctx, restore), "commit log archiving must be enabled in the datacenter of the backup")
+	restore.Spec.Backup = "finished"
+	assert.NoError(t, v.ValidateCreate(ctx, restore))
+	remote := restore.DeepCopy()
+	remote.Spec.InPlace = false
+	remote.Spec.CassandraDatacenter.Name = "dc2"
+	assert.NoError(t, v.ValidateCreate(ctx, remote), "remote restores replay the commit logs too")
+	assert.Error(t, v.ValidateCreate(ctx, newRestore("", "dc1", true)), "the backup or the point in time is required")
+
+	selecting := newRestore("", "dc2", false)
+	selecting.Spec.BackupSelector = &BackupSelector{Latest: true, Datacenter: "dc1"}
+	assert.NoError(t, v.ValidateCreate(ctx, selecting), "the backup is selected by the controller")
+	selecting.Spec.Backup = "finished"
+	assert.Error(t, v.ValidateCreate(ctx, selecting), "the backup cannot be both named and selected")
+
+	pointInTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	assert.Error(t, v.ValidateCreate(ctx, restore), "the backup must finish before the point in time")
+}
+
+func TestValidateRestorePointInTime(t *testing.T) {
+	pointInTime := metav1.Now()
+	replay := &CommitLogReplay{RestoreDirectories: []string{"/var/lib/cassandra/archive"}}
+	assert.Empty(t, (&CassandraRestoreSpec{Backup: "backup"}).ValidatePointInTime())
+	assert.Empty(t, (&CassandraRestoreSpec{RestorePointInTime: &pointInTime, CommitLogReplay: replay, InPlace: true}).ValidatePointInTime())
+	assert.Empty(t, (&CassandraRestoreSpec{RestorePointInTime: &pointInTime, CommitLogReplay: replay}).ValidatePointInTime())
+	assert.Len(t, (&CassandraRestoreSpec{RestorePointInTime: &pointInTime}).ValidatePointInTime(), 1, "the replay of the commit logs is required")
+	assert.Len(t, (&CassandraRestoreSpec{Backup: "backup", CommitLogReplay: replay}).ValidatePointInTime(), 1, "the commit logs are only replayed to a point in time")
+	assert.Len(t, (&CassandraRestoreSpec{RestorePointInTime: &pointInTime, CommitLogReplay: &CommitLogReplay{}}).ValidatePointInTime(), 1)
+}
+
+func TestCommitLogArchivingEnabled(t *testing.T) {
+	assert.True(t, CommitLogArchivingEnabled(&cassdcapi.CassandraDatacenterSpec{Config: newCommitLogArchivingConfig()}))
+	assert.False(t, CommitLogArchivingEnabled(&cassdcapi.CassandraDatacenterSpec{}))
+	assert.False(t, CommitLogArchivingEnabled(&cassdcapi.CassandraDatacenterSpec{Config: json.RawMessage(`{"cassandra-yaml":{}}`)}))
+	assert.False(t, CommitLogArchivingEnabled(&cassdcapi.CassandraDatacenterSpec{Config: json.RawMessage(`{"commitlog-archiving-properties":{"archive_command":""}}`)}))
+}
+
+// newCommitLogArchivingConfig returns a datacenter config that enables commit log archiving.
+func newCommitLogArchivingConfig() json.RawMessage {
+	return json.RawMessage(`{"commitlog-archiving-properties":{"archive_command":"/bin/ln %path /var/lib/cassandra/archive/%name"}}`)
+}
+
+func TestValidateBackupSelector(t *testing.T) {
+	pointInTime := metav1.Now()
+	dc1 := CassandraDatacenterConfig{Name: "dc1"}
+	assert.Empty(t, (&CassandraRestoreSpec{Backup: "backup"}).ValidateBackupSelector())
+	assert.Empty(t, (&CassandraRestoreSpec{RestorePointInTime: &pointInTime}).ValidateBackupSelector())
+	assert.Empty(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{Latest: true}}).ValidateBackupSelector())
+	assert.Empty(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{Before: &pointInTime}}).ValidateBackupSelector())
+	assert.Empty(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{
+		Datacenter:    "dc2",
+		Latest:        true,
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+	}, CassandraDatacenter: dc1}).ValidateBackupSelector(), "remote restores can select the backups of another datacenter")
+
+	assert.Len(t, (&CassandraRestoreSpec{}).ValidateBackupSelector(), 1, "the backup must be named or selected")
+	assert.Len(t, (&CassandraRestoreSpec{Backup: "backup", BackupSelector: &BackupSelector{Latest: true}}).ValidateBackupSelector(), 1)
+	assert.Len(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{}}).ValidateBackupSelector(), 1, "the selector cannot be empty")
+	assert.Len(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+	}}).ValidateBackupSelector(), 1, "a label selector only narrows down the candidates, latest or before must be set")
+	assert.Len(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{Latest: true, Before: &pointInTime}}).ValidateBackupSelector(), 1)
+	assert.Len(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{Latest: true, Datacenter: "dc2"}, InPlace: true, CassandraDatacenter: dc1}).ValidateBackupSelector(), 1)
+	assert.NotEmpty(t, (&CassandraRestoreSpec{BackupSelector: &BackupSelector{Latest: true, LabelSelector: &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}},
+	}}}).ValidateBackupSelector())
+}
+
+func TestSelectBackup(t *testing.T) {
+	now := time.Now()
+	newBackup := func(name, dc string, finishTime time.Time, succeeded bool, labels map[string]string) CassandraBackup {
+		backup := CassandraBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec:       CassandraBackupSpec{CassandraDatacenter: dc},
+			Status:     CassandraBackupStatus{FinishTime: metav1.NewTime(finishTime)},
+		}
+		if succeeded {
+			backup.Status.Conditions = []metav1.Condition{{Type: BackupConditionSucceeded, Status: metav1.ConditionTrue}}
+		}
+		return backup
+	}
+	daily := map[string]string{"schedule": "daily"}
+	backups := []CassandraBackup{
+		newBackup("old", "dc1", now.Add(-3*time.Hour), true, daily),
+		newBackup("newest-before", "dc1", now.Add(-2*time.Hour), true, nil),
+		newBackup("failed", "dc1", now.Add(-90*time.Minute), false, nil),
+		newBackup("other-dc", "dc2", now.Add(-90*time.Minute), true, nil),
+		newBackup("latest", "dc1", now, true, nil),
+	}
+	selected := func(spec *CassandraRestoreSpec) string {
+		if backup := spec.SelectBackup(backups); backup != nil {
+			return backup.Name
+		}
+		return ""
+	}
+	dc1 := CassandraDatacenterConfig{Name: "dc1"}
+	before := metav1.NewTime(now.Add(-time.Hour))
+	earlier := metav1.NewTime(now.Add(-150 * time.Minute))
+
+	assert.Equal(t, "latest", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{Latest: true}}))
+	assert.Equal(t, "newest-before", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{Before: &before}}))
+	assert.Equal(t, "newest-before", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, RestorePointInTime: &before}))
+	assert.Equal(t, "old", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, RestorePointInTime: &before, BackupSelector: &BackupSelector{Before: &earlier}}),
+		"the earliest deadline applies")
+	assert.Equal(t, "old", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{
+		Latest:        true,
+		LabelSelector: &metav1.LabelSelector{MatchLabels: daily},
+	}}))
+	assert.Equal(t, "old", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{
+		Before:        &before,
+		LabelSelector: &metav1.LabelSelector{MatchLabels: daily},
+	}}))
+	assert.Empty(t, selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: daily},
+	}}), "a selector without latest or before does not select a backup")
+	assert.Equal(t, "latest", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1}), "without a selector, the newest backup is restored")
+	assert.Equal(t, "other-dc", selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, BackupSelector: &BackupSelector{Latest: true, Datacenter: "dc2"}}))
+	assert.Empty(t, selected(&CassandraRestoreSpec{CassandraDatacenter: dc1, RestorePointInTime: &metav1.Time{Time: now.Add(-4 * time.Hour)}}))
+	assert.Empty(t, selected(&CassandraRestoreSpec{CassandraDatacenter: CassandraDatacenterConfig{Name: "dc3"}, BackupSelector: &BackupSelector{Latest: true}}))
+}
+
+func TestValidateRestoreKeyspaces(t *testing.T) {
+	spec := &CassandraRestoreSpec{
+		Keyspaces:        []string{"ks1"},
+		Tables:           []string{"ks2.table1", "ks2.table2"},
+		ExcludeKeyspaces: []string{"ks3"},
+		ExcludeTables:    []string{"ks1.table1"},
+	}
+	assert.Empty(t, spec.ValidateKeyspaces())
+
+	invalid := &CassandraRestoreSpec{
+		Keyspaces: []string{"ks-1"},
+		Tables:    []string{"table1", "ks.table.1"},
+	}
+	assert.Len(t, invalid.ValidateKeyspaces(), 3)
+
+	inconsistent := &CassandraRestoreSpec{
+		Keyspaces:        []string{"ks1"},
+		Tables:           []string{"ks2.table1", "ks3.table1"},
+		ExcludeKeyspaces: []string{"ks1", "ks2"},
+		ExcludeTables:    []string{"ks3.table1"},
+	}
+	errs := inconsistent.ValidateKeyspaces()
+	require.Len(t, errs, 3)
+	assert.Equal(t, "spec.keyspaces[0]", errs[0].Field)
+	assert.Equal(t, "spec.tables[0]", errs[1].Field)
+	assert.Equal(t, "spec.tables[1]", errs[2].Field)
+}
+
+func TestValidateBackupKeyspaces(t *testing.T) {
+	spec := &CassandraBackupSpec{Keyspaces: []string{"ks1"}, ExcludeKeyspaces: []string{"ks2"}}
+	assert.Empty(t, spec.ValidateKeyspaces())
+
+	spec = &CassandraBackupSpec{Keyspaces: []string{"ks1", "ks-2"}, ExcludeKeyspaces: []string{"ks1"}}
+	assert.Len(t, spec.ValidateKeyspaces(), 2)
+}
+
+func TestValidateRestoreKeyspacesInBackup(t *testing.T) {
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Status:     CassandraBackupStatus{ExcludeKeyspaces: []string{"analytics"}},
+	}
+	spec := &CassandraRestoreSpec{Keyspaces: []string{"ks1"}, Tables: []string{"ks2.table1"}}
+	assert.Empty(t, spec.ValidateKeyspacesInBackup(backup))
+
+	spec = &CassandraRestoreSpec{Keyspaces: []string{"analytics"}, Tables: []string{"analytics.events"}}
+	assert.Len(t, spec.ValidateKeyspacesInBackup(backup), 2)
+
+	backup.Status = CassandraBackupStatus{Keyspaces: []string{"ks1"}}
+	spec = &CassandraRestoreSpec{Keyspaces: []string{"ks1"}, Tables: []string{"ks2.table1"}}
+	errs := spec.ValidateKeyspacesInBackup(backup)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "spec.tables[0]", errs[0].Field)
+
+	assert.Empty(t, (&CassandraRestoreSpec{}).ValidateKeyspacesInBackup(backup), "restoring everything is always possible")
+}
+
+func TestValidateRestoreUpdate(t *testing.T) {
+	ctx := context.Background()
+	backup := &CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       CassandraBackupSpec{CassandraDatacenter: "dc1"},
+		Status: CassandraBackupStatus{
+			FinishTime: metav1.Now(),
+			Conditions: []metav1.Condition{{Type: BackupConditionSucceeded, Status: metav1.ConditionTrue}},
+		},
+	}
+	v := &CassandraRestoreValidator{Client: newWebhookClient(t, backup)}
+
+	old := &CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "restore"},
+		Spec:       CassandraRestoreSpec{Backup: "backup", InPlace: true, CassandraDatacenter: CassandraDatacenterConfig{Name: "dc1"}},
+	}
+
+	updated := old.DeepCopy()
+	updated.Spec.Shutdown = true
+	assert.NoError(t, v.ValidateUpdate(ctx, updated, old))
+
+	invalid := old.DeepCopy()
+	invalid.Spec.Backup = "missing"
+	assert.Error(t, v.ValidateUpdate(ctx, invalid, old), "the backup of a restore that has not started is validated")
+	invalid = old.DeepCopy()
+	invalid.Spec.BackupSelector = &BackupSelector{Latest: true}
+	assert.Error(t, v.ValidateUpdate(ctx, invalid, old), "the backup cannot be both named and selected")
+
+	orphaned := old.DeepCopy()
+	orphaned.Spec.Backup = "deleted"
+	relabeled := orphaned.DeepCopy()
+	relabeled.Labels = map[string]string{"team": "storage"}
+	relabeled.Finalizers = nil
+	assert.NoError(t, v.ValidateUpdate(ctx, relabeled, orphaned), "metadata updates do not depend on the backup")
+
+	old.Status.StartTime = metav1.Now()
+	assert.Error(t, v.ValidateUpdate(ctx, updated, old))
+	assert.NoError(t, v.ValidateUpdate(ctx, old.DeepCopy(), old))
+}
+
+func TestValidateHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &CassandraBackupSpec{}
+	assert.Empty(spec.ValidateHooks())
+
+	spec.Hooks = &BackupHooks{
+		Pre: []BackupHook{
+			{Name: "flush", Exec: &ExecHook{Command: []string{"nodetool", "flush"}}},
+			{Name: "notify", Job: &JobHook{}},
+		},
+		Post: []BackupHook{
+			{Name: "flush", Exec: &ExecHook{Command: []string{"nodetool", "flush"}}},
+		},
+	}
+	assert.Empty(spec.ValidateHooks(), "names only need to be unique within a stage")
+
+	spec.Hooks.Pre = append(spec.Hooks.Pre,
+		BackupHook{Name: "flush", Exec: &ExecHook{Command: []string{"true"}}},
+		BackupHook{Name: "Invalid_Name", Job: &JobHook{}},
+		BackupHook{Name: "none"},
+		BackupHook{Name: "both", Exec: &ExecHook{Command: []string{"true"}}, Job: &JobHook{}},
+		BackupHook{Name: "empty", Exec: &ExecHook{}},
+	)
+	assert.Len(spec.ValidateHooks(), 5)
+}
@@ -2,22 +2,40 @@ package controllers
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
 	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	operrors "github.com/k8ssandra/medusa-operator/pkg/errors"
 	"github.com/k8ssandra/medusa-operator/pkg/medusa"
 	"github.com/k8ssandra/medusa-operator/pkg/pb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 func testBackupDatacenter(t *testing.T, ctx context.Context, namespace string) {
@@ -77,30 +95,10 @@ func testBackupDatacenter(t *testing.T, ctx context.Context, namespace string) {
 	assert.NoError(err, "failed to create datacenter")
 
 	t.Logf("creating datacenter service")
-
-	dcServiceKey := types.NamespacedName{Namespace: dcKey.Namespace, Name: dc.GetAllPodsServiceName()}
-	dcService := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: dcServiceKey.Namespace,
-			Name:      dcServiceKey.Name,
-		},
-		Spec: corev1.ServiceSpec{
-			Selector: map[string]string{
-				cassdcapi.ClusterLabel: dc.Spec.ClusterName,
-			},
-			Ports: []corev1.ServicePort{
-				{
-					Name: "cql",
-					Port: 9042,
-				},
-			},
-		},
-	}
-	err = testClient.Create(ctx, dcService)
-	assert.NoError(err, "failed to create datacenter service %s", dcServiceKey)
+	createDatacenterService(t, ctx, dc)
 
 	t.Log("creating datacenter pods")
-	createDatacenterPods(t, ctx, dc)
+	createDatacenterPods(t, ctx, dc, 0)
 
 	t.Log("make the datacenter ready")
 	patch := client.MergeFrom(dc.DeepCopy())
@@ -179,6 +177,46 @@ func testBackupDatacenter(t *testing.T, ctx context.Context, namespace string) {
 		return len(updated.Status.Finished) == 3 && len(updated.Status.InProgress) == 0
 	}, timeout, interval)
 
+	t.Log("verify the backup conditions")
+	updated := &api.CassandraBackup{}
+	err = testClient.Get(context.Background(), backupKey, updated)
+	require.NoError(err, "failed to get CassandraBackup")
+	assert.True(meta.IsStatusConditionTrue(updated.Status.Conditions, api.BackupConditionStarted))
+	assert.True(updated.IsSucceeded())
+	assert.False(updated.IsFailed())
+	assert.Equal(api.BackupPhaseSucceeded, updated.Status.Phase)
+
+	t.Log("verify the status of the nodes")
+	require.Len(updated.Status.Nodes, 3)
+	for _, node := range updated.Status.Nodes {
+		assert.Equal(api.NodeBackupFinished, node.State, "pod %s", node.Pod)
+		assert.Equal("rack1", node.Rack, "pod %s", node.Pod)
+		assert.NotEmpty(node.Host, "pod %s", node.Pod)
+		assert.Equal(int32(1), node.Attempts, "pod %s", node.Pod)
+		assert.False(node.FinishTime.IsZero(), "pod %s", node.Pod)
+		assert.Empty(node.LastError, "pod %s", node.Pod)
+	}
+
+	t.Log("verify the summary of the backup in storage")
+	require.NotNil(updated.Status.Summary)
+	assert.Equal(int32(3), updated.Status.Summary.TotalNodes)
+	assert.Equal(int32(3), updated.Status.Summary.FinishedNodes)
+	assert.Len(updated.Status.Summary.Nodes, 3)
+	for _, node := range updated.Status.Summary.Nodes {
+		assert.NotEmpty(node.Host)
+		assert.Len(node.Tokens, 1, "host %s", node.Host)
+	}
+	assert.False(updated.Status.Summary.FinishTime.IsZero())
+	assert.True(updated.Status.FinishTime.Equal(&updated.Status.Summary.FinishTime), "the finish time is taken from storage")
+
+	t.Log("verify that events are recorded for the backup")
+	require.Eventually(func() bool {
+		return hasEvent(t, updated, "BackupStarted") && hasEvent(t, updated, "BackupSucceeded")
+	}, timeout, interval)
+
+	t.Log("verify that metrics are recorded for the backup")
+	assert.Equal(float64(1), testutil.ToFloat64(backupsSucceeded.WithLabelValues(namespace, TestCassandraDatacenterName, string(backup.Spec.Type))))
+
 	t.Log("verify that medusa gRPC clients are invoked")
 	assert.Equal(medusaClientFactory.GetRequestedBackups(), map[string][]string{
 		fmt.Sprintf("%s:%d", getPodIpAddress(0), backupSidecarPort): {backupName},
@@ -187,15 +225,453 @@ func testBackupDatacenter(t *testing.T, ctx context.Context, namespace string) {
 	})
 }
 
-func createDatacenterPods(t *testing.T, ctx context.Context, dc *cassdcapi.CassandraDatacenter) {
+func testBackupDeletionPolicy(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupName := "test-backup-purge"
+	backupKey := types.NamespacedName{Namespace: namespace, Name: backupName}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupName,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                backupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			DeletionPolicy:      api.DeleteBackup,
+		},
+	}
+
+	t.Log("creating CassandraBackup with Delete policy")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the finalizer is added and the backup finished")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return controllerutil.ContainsFinalizer(updated, backupFinalizer) && !updated.Status.FinishTime.IsZero()
+	}, timeout, interval)
+
+	t.Log("deleting CassandraBackup")
+	err = testClient.Delete(ctx, backup)
+	require.NoError(err, "failed to delete CassandraBackup")
+
+	t.Log("verify that the CassandraBackup is removed")
+	require.Eventually(func() bool {
+		err := testClient.Get(ctx, backupKey, &api.CassandraBackup{})
+		return errors.IsNotFound(err)
+	}, timeout, interval)
+
+	t.Log("verify that the backup is purged from storage")
+	require.Contains(medusaClientFactory.GetDeletedBackups(), backupName)
+}
+
+func testBackupKeyspaces(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupName := "test-backup-keyspaces"
+	backupKey := types.NamespacedName{Namespace: namespace, Name: backupName}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupName,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                backupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			Keyspaces:           []string{"ks1", "ks2"},
+		},
+	}
+
+	t.Log("creating CassandraBackup of a subset of keyspaces")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup finished")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return !updated.Status.FinishTime.IsZero()
+	}, timeout, interval)
+
+	updated := &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, updated)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Equal([]string{"ks1", "ks2"}, updated.Status.Keyspaces)
+	require.True(updated.ContainsKeyspace("ks1"))
+	require.False(updated.ContainsKeyspace("ks3"))
+
+	t.Log("verify that the keyspaces are passed to the sidecars")
+	medusaClientFactory.clientsMutex.Lock()
+	defer medusaClientFactory.clientsMutex.Unlock()
+	require.NotEmpty(medusaClientFactory.clients)
+	for _, c := range medusaClientFactory.clients {
+		require.Equal([]string{"ks1", "ks2"}, c.getRequestedKeyspaces(backupName))
+	}
+}
+
+func testBackupNodeError(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: failingBackupName}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      failingBackupName,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                failingBackupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+		},
+	}
+
+	t.Log("creating CassandraBackup that fails on the sidecars")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the errors are recorded in the status of the nodes")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		if len(updated.Status.Nodes) == 0 {
+			return false
+		}
+		for _, node := range updated.Status.Nodes {
+			if node.LastError != "failed to upload backup "+failingBackupName {
+				return false
+			}
+		}
+		return true
+	}, timeout, interval)
+
+	t.Log("verify that the backup failed without retries")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsFailed()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Len(backup.Status.Failed, 3)
+	for _, node := range backup.Status.Nodes {
+		require.Equal(api.NodeBackupFailed, node.State, "pod %s", node.Pod)
+		require.Equal(int32(1), node.Attempts, "pod %s", node.Pod)
+	}
+}
+
+func testBackupRetry(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: flakyBackupName}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      flakyBackupName,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                flakyBackupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			RetryPolicy: &api.RetryPolicy{
+				MaxAttempts:    2,
+				InitialBackoff: &metav1.Duration{Duration: time.Second},
+			},
+		},
+	}
+
+	t.Log("creating CassandraBackup that fails once on the sidecars")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup succeeded after a retry")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsSucceeded()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Len(backup.Status.Finished, 3)
+	require.Empty(backup.Status.Failed)
+	for _, node := range backup.Status.Nodes {
+		require.Equal(api.NodeBackupFinished, node.State, "pod %s", node.Pod)
+		require.Equal(int32(2), node.Attempts, "pod %s", node.Pod)
+		require.Equal("failed to upload backup "+flakyBackupName, node.LastError, "pod %s", node.Pod)
+	}
+}
+
+func testBackupTimeout(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-timeout"}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                hangingBackupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			NodeTimeout:         &metav1.Duration{Duration: time.Second},
+		},
+	}
+
+	t.Log("creating CassandraBackup that never finishes on the sidecars")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup timed out")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsFailed()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Equal("BackupTimedOut", meta.FindStatusCondition(backup.Status.Conditions, api.BackupConditionFailed).Reason)
+	for _, node := range backup.Status.Nodes {
+		require.Equal(api.NodeBackupTimedOut, node.State, "pod %s", node.Pod)
+	}
+}
+
+func testBackupCancel(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-cancel"}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                hangingBackupName,
+			CassandraDatacenter: TestCassandraDatacenterName,
+		},
+	}
+
+	t.Log("creating CassandraBackup that never finishes on the sidecars")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup started")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return len(updated.Status.InProgress) > 0
+	}, timeout, interval)
+
+	t.Log("cancelling the backup")
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return err
+		}
+		updated.Spec.Cancel = true
+		return testClient.Update(ctx, updated)
+	})
+	require.NoError(err, "failed to cancel CassandraBackup")
+
+	t.Log("verify that the backup is cancelled")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsFailed()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Equal("BackupCancelled", meta.FindStatusCondition(backup.Status.Conditions, api.BackupConditionFailed).Reason)
+	for _, node := range backup.Status.Nodes {
+		require.Equal(api.NodeBackupCancelled, node.State, "pod %s", node.Pod)
+	}
+
+	t.Log("verify that a backup cancelled before it started is finished")
+	pendingKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-cancel-pending"}
+	pending := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      pendingKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                pendingKey.Name,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			Cancel:              true,
+		},
+	}
+	err = testClient.Create(ctx, pending)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, pendingKey, updated); err != nil {
+			return false
+		}
+		return backupFinished(updated)
+	}, timeout, interval)
+
+	pending = &api.CassandraBackup{}
+	err = testClient.Get(ctx, pendingKey, pending)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.True(pending.IsFailed())
+	require.True(pending.Status.StartTime.IsZero(), "the backup is not started")
+	require.Equal("BackupCancelled", meta.FindStatusCondition(pending.Status.Conditions, api.BackupConditionFailed).Reason)
+}
+
+func testBackupConcurrencyLimit(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-sequential"}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                backupKey.Name,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			MaxConcurrentNodes:  1,
+			RackByRack:          true,
+		},
+	}
+
+	t.Log("creating CassandraBackup that backs up one node at a time")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup succeeded")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsSucceeded()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Len(backup.Status.Finished, 3)
+	require.Empty(backup.Status.Pending)
+
+	t.Log("verify that each node started once the previous one finished")
+	for i := 1; i < len(backup.Status.Nodes); i++ {
+		previous, node := backup.Status.Nodes[i-1], backup.Status.Nodes[i]
+		require.Equal(int32(1), node.Attempts, "pod %s", node.Pod)
+		require.False(node.StartTime.Before(&previous.FinishTime), "pod %s started before pod %s finished", node.Pod, previous.Pod)
+	}
+}
+
+func testGeneratedBackupName(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	t.Log("creating CassandraBackup without a name")
+	generated := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-backup-generated",
+		},
+		Spec: api.CassandraBackupSpec{
+			CassandraDatacenter: TestCassandraDatacenterName,
+		},
+	}
+	err := testClient.Create(ctx, generated)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup name is generated")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, client.ObjectKeyFromObject(generated), updated); err != nil {
+			return false
+		}
+		return strings.HasPrefix(updated.Spec.Name, TestCassandraDatacenterName+"-differential-") &&
+			metav1.HasAnnotation(updated.ObjectMeta, api.GeneratedBackupNameAnnotation)
+	}, timeout, interval)
+
+	t.Log("creating CassandraBackup with a generated name that already exists in storage")
+	duplicate := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        "test-backup-duplicate",
+			Annotations: map[string]string{api.GeneratedBackupNameAnnotation: "true"},
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                "test-backup",
+			CassandraDatacenter: TestCassandraDatacenterName,
+		},
+	}
+	err = testClient.Create(ctx, duplicate)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that a suffix is appended to the backup name")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, client.ObjectKeyFromObject(duplicate), updated); err != nil {
+			return false
+		}
+		return updated.Spec.Name == "test-backup-1" && !updated.Status.FinishTime.IsZero()
+	}, timeout, interval)
+}
+
+// createDatacenterService creates the all pods service of the datacenter, which is used to
+// find its pods.
+func createDatacenterService(t *testing.T, ctx context.Context, dc *cassdcapi.CassandraDatacenter) {
+	dcService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: dc.Namespace,
+			Name:      dc.GetAllPodsServiceName(),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				cassdcapi.ClusterLabel:    dc.Spec.ClusterName,
+				cassdcapi.DatacenterLabel: dc.Name,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name: "cql",
+					Port: 9042,
+				},
+			},
+		},
+	}
+	err := testClient.Create(ctx, dcService)
+	assert.NoError(t, err, "failed to create datacenter service %s", dcService.Name)
+}
+
+// createDatacenterPods creates the pods of the datacenter. Their IP addresses start at the
+// given index so that the pods of different datacenters do not share them.
+func createDatacenterPods(t *testing.T, ctx context.Context, dc *cassdcapi.CassandraDatacenter, firstIpIndex int) {
 	for i := int32(0); i < dc.Spec.Size; i++ {
 		pod := &corev1.Pod{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: dc.Namespace,
-				Name:      fmt.Sprintf("%s-%d", dc.Spec.ClusterName, i),
+				Name:      fmt.Sprintf("%s-%s-%d", dc.Spec.ClusterName, dc.Name, i),
 				Labels: map[string]string{
 					cassdcapi.ClusterLabel:    dc.Spec.ClusterName,
 					cassdcapi.DatacenterLabel: dc.Name,
+					cassdcapi.RackLabel:       "rack1",
 				},
 			},
 			Spec: corev1.PodSpec{
@@ -215,7 +691,7 @@ func createDatacenterPods(t *testing.T, ctx context.Context, dc *cassdcapi.Cassa
 		assert.NoError(t, err, "failed to create datacenter pod")
 
 		patch := client.MergeFrom(pod.DeepCopy())
-		pod.Status.PodIP = getPodIpAddress(int(i))
+		pod.Status.PodIP = getPodIpAddress(firstIpIndex + int(i))
 
 		err = testClient.Status().Patch(ctx, pod, patch)
 		assert.NoError(t, err, "failed to patch datacenter pod status")
@@ -230,45 +706,588 @@ func getPodIpAddress(index int) string {
 type fakeMedusaClientFactory struct {
 	clientsMutex sync.Mutex
 	clients      map[string]*fakeMedusaClient
+
+	// storedBackups are listed by the sidecars in addition to the requested backups, as if
+	// they had been taken outside of the operator.
+	storedBackups []*pb.BackupSummary
+
+	// finishTimesMutex guards finishTimes, the times at which the requested backups were
+	// first listed, which the fake sidecars report as their finish time.
+	finishTimesMutex sync.Mutex
+	finishTimes      map[string]time.Time
 }
 
 func NewMedusaClientFactory() *fakeMedusaClientFactory {
-	return &fakeMedusaClientFactory{clients: make(map[string]*fakeMedusaClient, 0)}
+	return &fakeMedusaClientFactory{
+		clients:     make(map[string]*fakeMedusaClient, 0),
+		finishTimes: make(map[string]time.Time),
+	}
 }
 
-func (f *fakeMedusaClientFactory) NewClient(address string) (medusa.Client, error) {
-	medusaClient := newFakeMedusaClient()
+func (f *fakeMedusaClientFactory) NewClient(address string, tlsConfig *tls.Config) (medusa.Client, error) {
 	f.clientsMutex.Lock()
-	f.clients[address] = medusaClient
-	f.clientsMutex.Unlock()
+	defer f.clientsMutex.Unlock()
+	medusaClient, found := f.clients[address]
+	if !found {
+		medusaClient = newFakeMedusaClient(f)
+		f.clients[address] = medusaClient
+	}
 	return medusaClient, nil
 }
 
+// getBackupStatus reports the nodes on which the backup was requested as finished. Nodes
+// are identified by their IP address.
+func (f *fakeMedusaClientFactory) getBackupStatus(name string) *pb.BackupStatusResponse {
+	f.clientsMutex.Lock()
+	defer f.clientsMutex.Unlock()
+	status := &pb.BackupStatusResponse{}
+	for address, c := range f.clients {
+		for _, requested := range c.getRequestedBackups() {
+			if requested == name {
+				host := strings.Split(address, ":")[0]
+				status.FinishedNodes = append(status.FinishedNodes, host)
+				break
+			}
+		}
+	}
+	return status
+}
+
 func (f *fakeMedusaClientFactory) GetRequestedBackups() map[string][]string {
+	f.clientsMutex.Lock()
+	defer f.clientsMutex.Unlock()
 	requestedBackups := make(map[string][]string)
 	for k, v := range f.clients {
-		requestedBackups[k] = v.RequestedBackups
+		requestedBackups[k] = v.getRequestedBackups()
 	}
 	return requestedBackups
 }
 
+// GetDeletedBackups returns the names of the backups deleted across all clients.
+func (f *fakeMedusaClientFactory) GetDeletedBackups() []string {
+	f.clientsMutex.Lock()
+	defer f.clientsMutex.Unlock()
+	deletedBackups := make([]string, 0)
+	for _, v := range f.clients {
+		deletedBackups = append(deletedBackups, v.getDeletedBackups()...)
+	}
+	return deletedBackups
+}
+
+// getBackupSummary reports the nodes on which the backup was requested as the nodes of the
+// backup, all of them finished.
+func (f *fakeMedusaClientFactory) getBackupSummary(name string) *pb.BackupSummary {
+	status := f.getBackupStatus(name)
+	sort.Strings(status.FinishedNodes)
+
+	f.finishTimesMutex.Lock()
+	finishTime, found := f.finishTimes[name]
+	if !found {
+		finishTime = time.Now()
+		f.finishTimes[name] = finishTime
+	}
+	f.finishTimesMutex.Unlock()
+
+	summary := &pb.BackupSummary{
+		BackupName:    name,
+		StartTime:     finishTime.Add(-time.Minute).Unix(),
+		FinishTime:    finishTime.Unix(),
+		TotalNodes:    int32(len(status.FinishedNodes)),
+		FinishedNodes: int32(len(status.FinishedNodes)),
+	}
+	for i, host := range status.FinishedNodes {
+		summary.Nodes = append(summary.Nodes, &pb.BackupNode{Host: host, Tokens: []int64{int64(i) * 1000}})
+	}
+	return summary
+}
+
+// setStoredBackups replaces the backups listed by the sidecars that were not requested
+// from them.
+func (f *fakeMedusaClientFactory) setStoredBackups(backups ...*pb.BackupSummary) {
+	f.clientsMutex.Lock()
+	defer f.clientsMutex.Unlock()
+	f.storedBackups = backups
+}
+
+func (f *fakeMedusaClientFactory) getStoredBackups() []*pb.BackupSummary {
+	f.clientsMutex.Lock()
+	defer f.clientsMutex.Unlock()
+	return append([]*pb.BackupSummary{}, f.storedBackups...)
+}
+
+const (
+	// failingBackupName is the name of the backup for which the fake sidecars return an error.
+	failingBackupName = "test-backup-error"
+
+	// flakyBackupName is the name of the backup for which the fake sidecars return an error
+	// on the first attempt only.
+	flakyBackupName = "test-backup-flaky"
+
+	// hangingBackupName is the name of the backup that the fake sidecars never finish.
+	hangingBackupName = "test-backup-hanging"
+)
+
 type fakeMedusaClient struct {
-	RequestedBackups []string
+	factory            *fakeMedusaClientFactory
+	mutex              sync.Mutex
+	RequestedBackups   []string
+	RequestedKeyspaces map[string][]string
+	DeletedBackups     []string
+	Attempts           map[string]int
+}
+
+func newFakeMedusaClient(factory *fakeMedusaClientFactory) *fakeMedusaClient {
+	return &fakeMedusaClient{
+		factory:            factory,
+		RequestedBackups:   make([]string, 0),
+		RequestedKeyspaces: make(map[string][]string),
+		DeletedBackups:     make([]string, 0),
+		Attempts:           make(map[string]int),
+	}
+}
+
+func (c *fakeMedusaClient) getRequestedKeyspaces(name string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.RequestedKeyspaces[name]
+}
+
+func (c *fakeMedusaClient) getRequestedBackups() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string{}, c.RequestedBackups...)
 }
 
-func newFakeMedusaClient() *fakeMedusaClient {
-	return &fakeMedusaClient{RequestedBackups: make([]string, 0)}
+func (c *fakeMedusaClient) getDeletedBackups() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string{}, c.DeletedBackups...)
 }
 
 func (c *fakeMedusaClient) Close() error {
 	return nil
 }
 
-func (c *fakeMedusaClient) CreateBackup(ctx context.Context, name string, backupType string) error {
+func (c *fakeMedusaClient) CreateBackup(ctx context.Context, name string, backupType string, keyspaces, excludeKeyspaces []string) error {
+	if name == hangingBackupName {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Attempts[name]++
+	if name == failingBackupName || (name == flakyBackupName && c.Attempts[name] == 1) {
+		return fmt.Errorf("failed to upload backup %s", name)
+	}
 	c.RequestedBackups = append(c.RequestedBackups, name)
+	c.RequestedKeyspaces[name] = keyspaces
 	return nil
 }
 
 func (c *fakeMedusaClient) GetBackups(ctx context.Context) ([]*pb.BackupSummary, error) {
-	return nil, nil
+	backups := make([]*pb.BackupSummary, 0)
+	for _, name := range c.getRequestedBackups() {
+		backups = append(backups, c.factory.getBackupSummary(name))
+	}
+	return append(backups, c.factory.getStoredBackups()...), nil
+}
+
+func (c *fakeMedusaClient) BackupStatus(ctx context.Context, name string) (*pb.BackupStatusResponse, error) {
+	return c.factory.getBackupStatus(name), nil
+}
+
+func (c *fakeMedusaClient) DeleteBackup(ctx context.Context, name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.DeletedBackups = append(c.DeletedBackups, name)
+	return nil
+}
+
+func TestGetNodeBackupState(t *testing.T) {
+	assert := assert.New(t)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-dc1-default-sts-0"},
+		Status:     corev1.PodStatus{PodIP: getPodIpAddress(0)},
+	}
+	status := &pb.BackupStatusResponse{
+		FinishedNodes:   []string{getPodIpAddress(1)},
+		UnfinishedNodes: []string{"test-dc1-default-sts-0.test-dc1-service.default.svc.cluster.local"},
+		MissingNodes:    []string{getPodIpAddress(2)},
+	}
+
+	assert.Equal(nodeBackupUnfinished, getNodeBackupState(status, pod))
+
+	pod.Name = "test-dc1-default-sts-1"
+	pod.Status.PodIP = getPodIpAddress(1)
+	assert.Equal(nodeBackupFinished, getNodeBackupState(status, pod))
+
+	pod.Name = "test-dc1-default-sts-2"
+	pod.Status.PodIP = getPodIpAddress(2)
+	assert.Equal(nodeBackupMissing, getNodeBackupState(status, pod))
+
+	assert.Equal(nodeBackupMissing, getNodeBackupState(nil, pod))
+	assert.Equal(nodeBackupMissing, getNodeBackupState(status, nil))
+}
+
+func TestBackupStatusUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(backupStatusUnavailable(nil))
+	assert.False(backupStatusUnavailable(operrors.BackupSidecarNotFound), "there is no sidecar to wait for")
+	assert.False(backupStatusUnavailable(fmt.Errorf("failed to get status of backup test: %w", grpcstatus.Error(codes.NotFound, "unknown backup"))),
+		"the sidecar does not know the backup")
+	assert.True(backupStatusUnavailable(fmt.Errorf("failed to get status of backup test: %w", grpcstatus.Error(codes.Unavailable, "connection refused"))))
+	assert.True(backupStatusUnavailable(context.DeadlineExceeded))
+}
+
+func TestSetNodeFinished(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	backup := &api.CassandraBackup{Status: api.CassandraBackupStatus{
+		Nodes: []api.NodeBackupStatus{
+			{Pod: "pod-0", State: api.NodeBackupInProgress},
+			{Pod: "pod-1", State: api.NodeBackupInProgress, LastError: "connection refused"},
+		},
+	}}
+
+	setNodeFinished(backup, "pod-0", api.NodeBackupFailed, "timed out", now)
+	setNodeFinished(backup, "pod-1", api.NodeBackupFailed, "timed out", now)
+	setNodeFinished(backup, "pod-2", api.NodeBackupFinished, "", now)
+
+	assert.Equal(api.NodeBackupFailed, backup.Status.Nodes[0].State)
+	assert.Equal("timed out", backup.Status.Nodes[0].LastError)
+	assert.Equal(now.Unix(), backup.Status.Nodes[0].FinishTime.Unix())
+	assert.Equal("connection refused", backup.Status.Nodes[1].LastError, "the error of the sidecar is kept")
+	assert.Len(backup.Status.Nodes, 2)
+}
+
+func TestUniqueBackupName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("backup", uniqueBackupName("backup", map[string]bool{}))
+	assert.Equal("backup-1", uniqueBackupName("backup", map[string]bool{"backup": true}))
+	assert.Equal("backup-3", uniqueBackupName("backup", map[string]bool{"backup": true, "backup-1": true, "backup-2": true}))
+}
+
+// hasEvent returns true if an event with the given reason has been recorded for obj.
+func hasEvent(t *testing.T, obj client.Object, reason string) bool {
+	events := &corev1.EventList{}
+	if err := testClient.List(context.Background(), events, client.InNamespace(obj.GetNamespace())); err != nil {
+		t.Logf("failed to list events: %s", err)
+		return false
+	}
+	for _, event := range events.Items {
+		if event.InvolvedObject.UID == obj.GetUID() && event.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := newRetryPolicy(nil)
+	assert.Equal(int32(1), policy.maxAttempts)
+
+	policy = newRetryPolicy(&api.RetryPolicy{})
+	assert.Equal(int32(defaultMaxAttempts), policy.maxAttempts)
+	assert.Equal(defaultInitialBackoff, policy.backoff(1))
+	assert.Equal(2*defaultInitialBackoff, policy.backoff(2))
+	assert.Equal(4*defaultInitialBackoff, policy.backoff(3))
+	assert.Equal(defaultMaxBackoff, policy.backoff(10))
+
+	policy = newRetryPolicy(&api.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: &metav1.Duration{Duration: time.Minute},
+		MaxBackoff:     &metav1.Duration{Duration: 3 * time.Minute},
+	})
+	assert.Equal(int32(5), policy.maxAttempts)
+	assert.Equal(time.Minute, policy.backoff(1))
+	assert.Equal(2*time.Minute, policy.backoff(2))
+	assert.Equal(3*time.Minute, policy.backoff(3))
+
+	backup := &api.CassandraBackup{Status: api.CassandraBackupStatus{
+		Failed: []string{"pod-0"},
+		Nodes:  []api.NodeBackupStatus{{Pod: "pod-0", State: api.NodeBackupFailed, Attempts: 5}},
+	}}
+	assert.False(policy.hasRetriableNodes(backup))
+	backup.Status.Nodes[0].Attempts = 4
+	assert.True(policy.hasRetriableNodes(backup))
+}
+
+func TestBackupStopState(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	backup := &api.CassandraBackup{Status: api.CassandraBackupStatus{StartTime: metav1.NewTime(now.Add(-time.Hour))}}
+
+	state, _ := backupStopState(backup, now)
+	assert.Empty(state)
+
+	backup.Spec.Timeout = &metav1.Duration{Duration: 2 * time.Hour}
+	state, _ = backupStopState(backup, now)
+	assert.Empty(state)
+
+	backup.Spec.Timeout.Duration = 30 * time.Minute
+	state, reason := backupStopState(backup, now)
+	assert.Equal(api.NodeBackupTimedOut, state)
+	assert.Equal("the backup did not finish within 30m0s", reason)
+
+	backup.Spec.Cancel = true
+	state, _ = backupStopState(backup, now)
+	assert.Equal(api.NodeBackupCancelled, state)
+}
+
+func TestBackupTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	var tasks backupTasks
+	key := types.NamespacedName{Namespace: "default", Name: "backup"}
+
+	ctx0, done0 := tasks.start(key, "pod-0", 0)
+	ctx1, done1 := tasks.start(key, "pod-1", time.Hour)
+
+	tasks.cancel(key, "pod-0")
+	assert.Equal(context.Canceled, ctx0.Err())
+	assert.NoError(ctx1.Err())
+	done0()
+
+	retried, doneRetried := tasks.start(key, "pod-1", 0)
+	assert.Equal(context.Canceled, ctx1.Err(), "a retry replaces the previous request")
+	done1()
+	assert.NoError(retried.Err(), "the previous request does not cancel the retry")
+
+	tasks.cancel(key, "pod-1")
+	assert.Equal(context.Canceled, retried.Err(), "the previous request does not remove the retry")
+	doneRetried()
+	assert.Empty(tasks.tasks, "completed requests are forgotten")
+
+	ctx2, done2 := tasks.start(key, "pod-2", 0)
+	other, doneOther := tasks.start(types.NamespacedName{Namespace: "default", Name: "other"}, "pod-2", 0)
+	tasks.cancelAll(key)
+	assert.Equal(context.Canceled, ctx2.Err())
+	assert.NoError(other.Err(), "the requests of other backups keep running")
+	done2()
+	doneOther()
+}
+
+func TestSortPodsByRack(t *testing.T) {
+	pod := func(name, rack string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{cassdcapi.RackLabel: rack}}}
+	}
+	pods := []corev1.Pod{pod("pod-3", "rack1"), pod("pod-0", "other"), pod("pod-2", "rack2"), pod("pod-1", "rack1")}
+	sortPodsByRack(pods, []cassdcapi.Rack{{Name: "rack2"}, {Name: "rack1"}})
+
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"pod-2", "pod-1", "pod-3", "pod-0"}, names)
+}
+
+func TestStartPendingNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	newBackup := func() *api.CassandraBackup {
+		return &api.CassandraBackup{Status: api.CassandraBackupStatus{
+			Pending: []string{"pod-0", "pod-1", "pod-2"},
+			Nodes: []api.NodeBackupStatus{
+				{Pod: "pod-0", Rack: "rack1", State: api.NodeBackupPending},
+				{Pod: "pod-1", Rack: "rack1", State: api.NodeBackupPending},
+				{Pod: "pod-2", Rack: "rack2", State: api.NodeBackupPending},
+			},
+		}}
+	}
+
+	backup := newBackup()
+	assert.Equal([]string{"pod-0", "pod-1", "pod-2"}, startPendingNodes(backup, nil, now), "all the nodes start without limits")
+	assert.Empty(backup.Status.Pending)
+	assert.Equal(api.NodeBackupInProgress, backup.Status.Nodes[0].State)
+	assert.Equal(int32(1), backup.Status.Nodes[0].Attempts)
+
+	backup = newBackup()
+	backup.Spec.MaxConcurrentNodes = 2
+	assert.Equal([]string{"pod-0"}, startPendingNodes(backup, []string{"pod-3"}, now))
+	assert.Equal([]string{"pod-1", "pod-2"}, backup.Status.Pending)
+
+	backup = newBackup()
+	backup.Spec.RackByRack = true
+	assert.Equal([]string{"pod-0", "pod-1"}, startPendingNodes(backup, nil, now))
+	assert.Empty(startPendingNodes(backup, []string{"pod-1"}, now), "the next rack waits for the busy nodes")
+	assert.Equal([]string{"pod-2"}, startPendingNodes(backup, nil, now))
+}
+
+func TestSetBackupSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	observed := metav1.NewTime(time.Unix(5000, 0))
+	backup := &api.CassandraBackup{Status: api.CassandraBackupStatus{FinishTime: observed}}
+	setBackupSummary(backup, &pb.BackupSummary{
+		BackupName:    "backup",
+		StartTime:     1000,
+		TotalNodes:    2,
+		FinishedNodes: 1,
+		Nodes: []*pb.BackupNode{
+			{Host: "10.0.0.1", Datacenter: "dc1", Rack: "rack1", Tokens: []int64{-100, 100}},
+			{Host: "10.0.0.2", Datacenter: "dc1", Rack: "rack2", Tokens: []int64{0}},
+		},
+	})
+	assert.True(backup.Status.FinishTime.Equal(&observed), "an incomplete backup keeps the observed finish time")
+	assert.Equal(int64(1000), backup.Status.Summary.StartTime.Unix())
+	assert.True(backup.Status.Summary.FinishTime.IsZero())
+	assert.Equal(int32(2), backup.Status.Summary.TotalNodes)
+	assert.Equal(int32(1), backup.Status.Summary.FinishedNodes)
+	assert.Equal([]api.BackupNode{
+		{Host: "10.0.0.1", Datacenter: "dc1", Rack: "rack1", Tokens: []int64{-100, 100}},
+		{Host: "10.0.0.2", Datacenter: "dc1", Rack: "rack2", Tokens: []int64{0}},
+	}, backup.Status.Summary.Nodes)
+
+	setBackupSummary(backup, &pb.BackupSummary{BackupName: "backup", StartTime: 1000, FinishTime: 2000, TotalNodes: 2, FinishedNodes: 2})
+	assert.Equal(int64(2000), backup.Status.FinishTime.Unix(), "the finish time recorded in storage is authoritative")
+}
+
+func TestRecordPodBackupFinished(t *testing.T) {
+	start := time.Now()
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "metrics-test"},
+		Spec:       api.CassandraBackupSpec{CassandraDatacenter: "dc1", Type: api.FullBackup},
+		Status: api.CassandraBackupStatus{
+			StartTime: metav1.NewTime(start.Add(-time.Hour)),
+			Nodes: []api.NodeBackupStatus{
+				{Pod: "pod-0", StartTime: metav1.NewTime(start), FinishTime: metav1.NewTime(start.Add(time.Minute))},
+			},
+		},
+	}
+
+	recordPodBackupFinished(backup, "pod-0")
+	recordPodBackupFinished(backup, "pod-1")
+
+	metric := &dto.Metric{}
+	observer := podBackupDuration.WithLabelValues("metrics-test", "dc1", string(api.FullBackup))
+	require.NoError(t, observer.(prometheus.Histogram).Write(metric))
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount(), "pods without a status are not observed")
+	assert.Equal(t, float64(60), metric.GetHistogram().GetSampleSum(), "the duration is measured from the start of the node")
+}
+
+func TestRecordBackupFailedBeforeStart(t *testing.T) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "metrics-test", Name: "invalid-keyspaces"},
+		Spec: api.CassandraBackupSpec{
+			Name:                "invalid-keyspaces",
+			CassandraDatacenter: "dc1",
+			Type:                api.FullBackup,
+			Keyspaces:           []string{"ks1"},
+			ExcludeKeyspaces:    []string{"ks1"},
+		},
+	}
+	c := newFakeDatacenterClient(t, backup)
+	r := &CassandraBackupReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10), ClientFactory: NewMedusaClientFactory()}
+	failed := backupsFailed.WithLabelValues("metrics-test", "dc1", string(api.FullBackup))
+	before := testutil.ToFloat64(failed)
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name}})
+	require.NoError(err)
+
+	updated := &api.CassandraBackup{}
+	require.NoError(c.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name}, updated))
+	require.True(updated.IsFailed())
+	require.Equal(before+1, testutil.ToFloat64(failed), "backups that fail before they start are counted")
+}
+
+// newFakeDatacenterClient returns a fake client with a datacenter named dc1 in the default
+// namespace and a single pod with the Medusa sidecar, along with the given objects.
+func newFakeDatacenterClient(t *testing.T, objs ...client.Object) client.Client {
+	require.NoError(t, registerApis())
+
+	dc := &cassdcapi.CassandraDatacenter{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dc1"},
+		Spec:       cassdcapi.CassandraDatacenterSpec{ClusterName: "test", Size: 1},
+	}
+	selector := map[string]string{cassdcapi.DatacenterLabel: dc.Name}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: dc.Namespace, Name: dc.GetAllPodsServiceName()},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: dc.Namespace, Name: "test-dc1-default-sts-0", Labels: selector},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "cassandra"}, {Name: backupSidecarName}},
+		},
+		Status: corev1.PodStatus{PodIP: getPodIpAddress(0)},
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(append(objs, dc, svc, pod)...).Build()
+}
+
+func TestPurgeBackupNotInStorage(t *testing.T) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	c := newFakeDatacenterClient(t)
+	factory := NewMedusaClientFactory()
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       api.CassandraBackupSpec{Name: "never-stored", CassandraDatacenter: "dc1"},
+	}
+
+	require.NoError(purgeBackup(ctx, c, backup, factory, medusa.TLSSettings{}), "a backup that is not in storage is already purged")
+	require.Empty(factory.GetDeletedBackups())
+
+	factory.setStoredBackups(&pb.BackupSummary{BackupName: "stored"})
+	backup.Spec.Name = "stored"
+	require.NoError(purgeBackup(ctx, c, backup, factory, medusa.TLSSettings{}))
+	require.Equal([]string{"stored"}, factory.GetDeletedBackups())
+}
+
+func TestFinalizeBackupNotInStorage(t *testing.T) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	newDeletedBackup := func(name, datacenter string) *api.CassandraBackup {
+		return &api.CassandraBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:  "default",
+				Name:       name,
+				Finalizers: []string{backupFinalizer},
+			},
+			Spec: api.CassandraBackupSpec{Name: name, CassandraDatacenter: datacenter, DeletionPolicy: api.DeleteBackup},
+			Status: api.CassandraBackupStatus{
+				StartTime:  metav1.Now(),
+				FinishTime: metav1.Now(),
+				Conditions: []metav1.Condition{{Type: api.BackupConditionFailed, Status: metav1.ConditionTrue}},
+			},
+		}
+	}
+	// The backup failed on all nodes before the sidecars registered it.
+	unregistered := newDeletedBackup("unregistered", "dc1")
+	orphaned := newDeletedBackup("orphaned", "deleted-dc")
+	c := newFakeDatacenterClient(t, unregistered, orphaned)
+	factory := NewMedusaClientFactory()
+	r := &CassandraBackupReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10), ClientFactory: factory}
+
+	result, err := r.finalizeBackup(ctx, unregistered)
+	require.NoError(err)
+	require.Equal(ctrl.Result{}, result)
+	require.Empty(factory.GetDeletedBackups())
+	updated := &api.CassandraBackup{}
+	require.NoError(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: unregistered.Name}, updated))
+	require.False(controllerutil.ContainsFinalizer(updated, backupFinalizer), "a backup that is not in storage is purged")
+
+	result, err = r.finalizeBackup(ctx, orphaned)
+	require.NoError(err)
+	require.Equal(ctrl.Result{}, result, "the purge is not retried until the backup is updated")
+	require.NoError(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: orphaned.Name}, updated))
+	require.True(controllerutil.ContainsFinalizer(updated, backupFinalizer))
+	purged := meta.FindStatusCondition(updated.Status.Conditions, api.BackupConditionPurged)
+	require.NotNil(purged)
+	require.Equal(metav1.ConditionFalse, purged.Status)
+	require.Equal("DatacenterNotFound", purged.Reason)
+	require.Contains(purged.Message, backupFinalizer, "the message tells how to release the finalizer")
 }
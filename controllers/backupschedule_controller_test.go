@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testBackupSchedule(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	schedule := &api.CassandraBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-schedule",
+		},
+		Spec: api.CassandraBackupScheduleSpec{
+			CronSchedule:      "0 * * * *",
+			ConcurrencyPolicy: api.AllowConcurrent,
+			BackupSpec: api.CassandraBackupSpec{
+				CassandraDatacenter: TestCassandraDatacenterName,
+				Type:                api.FullBackup,
+			},
+		},
+	}
+	scheduleKey := types.NamespacedName{Namespace: namespace, Name: schedule.Name}
+
+	t.Log("creating CassandraBackupSchedule")
+	err := testClient.Create(ctx, schedule)
+	require.NoError(err, "failed to create CassandraBackupSchedule")
+
+	t.Log("verify that no backup is created before the first scheduled time")
+	require.Never(func() bool {
+		return len(getScheduledBackups(t, ctx, scheduleKey)) > 0
+	}, 1*time.Second, interval)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.CronSchedule)
+	require.NoError(err)
+	scheduledTime := cronSchedule.Next(schedule.CreationTimestamp.Time)
+
+	t.Log("advance the clock past the scheduled time")
+	backupScheduleClock.SetTime(scheduledTime.Add(1 * time.Second))
+	err = touchBackupSchedule(ctx, scheduleKey)
+	require.NoError(err, "failed to trigger CassandraBackupSchedule reconciliation")
+
+	t.Log("verify that the scheduled backup is created")
+	require.Eventually(func() bool {
+		return len(getScheduledBackups(t, ctx, scheduleKey)) == 1
+	}, timeout, interval)
+
+	backup := getScheduledBackups(t, ctx, scheduleKey)[0]
+	backupName, err := api.GenerateBackupName(&backup, scheduledTime)
+	require.NoError(err)
+	require.Equal(backupName, backup.Spec.Name, "the backup name is generated from the template")
+	require.True(metav1.HasAnnotation(backup.ObjectMeta, api.GeneratedBackupNameAnnotation))
+	require.Equal(TestCassandraDatacenterName, backup.Spec.CassandraDatacenter)
+	require.Equal(api.FullBackup, backup.Spec.Type)
+	require.Len(backup.OwnerReferences, 1)
+	require.Equal(schedule.Name, backup.OwnerReferences[0].Name)
+
+	t.Log("verify that the schedule status is updated")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackupSchedule{}
+		if err := testClient.Get(ctx, scheduleKey, updated); err != nil {
+			return false
+		}
+		return updated.Status.LastScheduleTime.Time.Equal(scheduledTime) &&
+			updated.Status.LastSuccessfulBackup == backup.Name
+	}, timeout, interval)
+
+	t.Log("advance the clock past the next scheduled time")
+	backupScheduleClock.SetTime(cronSchedule.Next(scheduledTime).Add(1 * time.Second))
+	err = touchBackupSchedule(ctx, scheduleKey)
+	require.NoError(err, "failed to trigger CassandraBackupSchedule reconciliation")
+
+	t.Log("verify that a second backup is created")
+	require.Eventually(func() bool {
+		return len(getScheduledBackups(t, ctx, scheduleKey)) == 2
+	}, timeout, interval)
+}
+
+func testBackupScheduleRetention(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	// Reset the clock so that the schedule does not fire right away.
+	backupScheduleClock.SetTime(time.Now())
+
+	schedule := &api.CassandraBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-retention",
+		},
+		Spec: api.CassandraBackupScheduleSpec{
+			CronSchedule: "0 * * * *",
+			BackupSpec: api.CassandraBackupSpec{
+				CassandraDatacenter: TestCassandraDatacenterName,
+			},
+			Retention: &api.RetentionPolicy{
+				KeepLast: 1,
+			},
+		},
+	}
+	scheduleKey := types.NamespacedName{Namespace: namespace, Name: schedule.Name}
+
+	t.Log("creating CassandraBackupSchedule with retention policy")
+	err := testClient.Create(ctx, schedule)
+	require.NoError(err, "failed to create CassandraBackupSchedule")
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.CronSchedule)
+	require.NoError(err)
+	scheduledTime := cronSchedule.Next(schedule.CreationTimestamp.Time)
+
+	t.Log("trigger the first scheduled backup")
+	backupScheduleClock.SetTime(scheduledTime.Add(1 * time.Second))
+	err = touchBackupSchedule(ctx, scheduleKey)
+	require.NoError(err, "failed to trigger CassandraBackupSchedule reconciliation")
+
+	var firstBackup string
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackupSchedule{}
+		if err := testClient.Get(ctx, scheduleKey, updated); err != nil {
+			return false
+		}
+		firstBackup = updated.Status.LastSuccessfulBackup
+		return len(firstBackup) > 0
+	}, timeout, interval, "timed out waiting for the first scheduled backup to finish")
+
+	t.Log("trigger the second scheduled backup")
+	backupScheduleClock.SetTime(cronSchedule.Next(scheduledTime).Add(1 * time.Second))
+	err = touchBackupSchedule(ctx, scheduleKey)
+	require.NoError(err, "failed to trigger CassandraBackupSchedule reconciliation")
+
+	t.Log("verify that the first backup is deleted")
+	require.Eventually(func() bool {
+		backups := getScheduledBackups(t, ctx, scheduleKey)
+		return len(backups) == 1 && backups[0].Name != firstBackup
+	}, timeout, interval, "timed out waiting for the expired backup to be deleted")
+
+	t.Log("verify that the backup is purged from storage")
+	require.Contains(medusaClientFactory.GetDeletedBackups(), firstBackup)
+}
+
+func getScheduledBackups(t *testing.T, ctx context.Context, scheduleKey types.NamespacedName) []api.CassandraBackup {
+	backups := &api.CassandraBackupList{}
+	if err := testClient.List(ctx, backups, client.InNamespace(scheduleKey.Namespace), client.MatchingLabels{api.BackupScheduleLabel: scheduleKey.Name}); err != nil {
+		t.Logf("failed to list CassandraBackups: %s", err)
+		return nil
+	}
+	return backups.Items
+}
+
+// touchBackupSchedule updates an annotation on the CassandraBackupSchedule so that it gets
+// reconciled after the fake clock has been moved.
+func touchBackupSchedule(ctx context.Context, key types.NamespacedName) error {
+	schedule := &api.CassandraBackupSchedule{}
+	if err := testClient.Get(ctx, key, schedule); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(schedule.DeepCopy())
+	if schedule.Annotations == nil {
+		schedule.Annotations = map[string]string{}
+	}
+	schedule.Annotations["test/touched"] = backupScheduleClock.Now().String()
+
+	return testClient.Patch(ctx, schedule, patch)
+}
+
+// newFinishedBackup returns a backup that finished at the given time with the given
+// outcome.
+func newFinishedBackup(name string, finishTime time.Time, succeeded bool) api.CassandraBackup {
+	backup := api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: api.CassandraBackupStatus{
+			StartTime:  metav1.NewTime(finishTime.Add(-time.Minute)),
+			FinishTime: metav1.NewTime(finishTime),
+		},
+	}
+	conditionType := api.BackupConditionFailed
+	if succeeded {
+		conditionType = api.BackupConditionSucceeded
+	}
+	backup.Status.Conditions = []metav1.Condition{{Type: conditionType, Status: metav1.ConditionTrue}}
+	return backup
+}
+
+func TestSummarizeScheduledBackups(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	// An aborted backup fails without failed pods.
+	aborted := newFinishedBackup("aborted", now, false)
+	running := api.CassandraBackup{ObjectMeta: metav1.ObjectMeta{Name: "running"}}
+	active, lastSuccessful := summarizeScheduledBackups([]api.CassandraBackup{
+		newFinishedBackup("succeeded", now.Add(-time.Hour), true),
+		aborted,
+		running,
+	})
+	require.Len(active, 1)
+	require.Equal("running", active[0].Name)
+	require.NotNil(lastSuccessful)
+	require.Equal("succeeded", lastSuccessful.Name)
+}
+
+func TestExpiredBackupsIgnoresFailedBackups(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	backups := []api.CassandraBackup{
+		newFinishedBackup("old", now.Add(-2*time.Hour), true),
+		newFinishedBackup("succeeded", now.Add(-time.Hour), true),
+		newFinishedBackup("aborted", now, false),
+	}
+	expired := expiredBackups(&api.RetentionPolicy{KeepLast: 1}, backups, now)
+	names := make([]string, 0, len(expired))
+	for _, backup := range expired {
+		names = append(names, backup.Name)
+	}
+	require.Equal([]string{"old"}, names, "failed backups do not take the place of successful ones")
+}
+
+func TestNewScheduledBackup(t *testing.T) {
+	require := require.New(t)
+
+	r := &CassandraBackupScheduleReconciler{Scheme: scheme.Scheme}
+	require.NoError(api.AddToScheme(r.Scheme))
+	schedule := &api.CassandraBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly", UID: "uid"},
+		Spec: api.CassandraBackupScheduleSpec{
+			BackupSpec: api.CassandraBackupSpec{CassandraDatacenter: "dc1", Type: api.FullBackup},
+		},
+	}
+
+	scheduledTime := time.Date(2021, 11, 15, 10, 30, 0, 0, time.UTC)
+	backup, err := r.newScheduledBackup(schedule, scheduledTime)
+	require.NoError(err)
+	require.Equal("nightly-1636972200", backup.Name)
+	require.Equal("dc1-full-20211115103000", backup.Spec.Name, "the name is generated from the backup name template")
+	require.True(metav1.HasAnnotation(backup.ObjectMeta, api.GeneratedBackupNameAnnotation))
+
+	next, err := r.newScheduledBackup(schedule, scheduledTime.Add(time.Hour))
+	require.NoError(err)
+	require.NotEqual(backup.Spec.Name, next.Spec.Name, "each run gets its own name")
+}
+
+func TestMostRecentScheduleTime(t *testing.T) {
+	require := require.New(t)
+
+	schedule, err := cron.ParseStandard("* * * * *")
+	require.NoError(err)
+	now := time.Date(2021, 11, 15, 10, 30, 30, 0, time.UTC)
+
+	scheduled, tooManyMissed := mostRecentScheduleTime(schedule, now.Add(-10*time.Minute), now)
+	require.False(tooManyMissed)
+	require.NotNil(scheduled)
+	require.Equal(time.Date(2021, 11, 15, 10, 30, 0, 0, time.UTC), *scheduled, "missed schedules are collapsed")
+
+	scheduled, tooManyMissed = mostRecentScheduleTime(schedule, now.Add(-365*24*time.Hour), now)
+	require.True(tooManyMissed)
+	require.NotNil(scheduled)
+	require.Equal(now, *scheduled)
+
+	scheduled, tooManyMissed = mostRecentScheduleTime(schedule, now.Add(-time.Second), now)
+	require.False(tooManyMissed)
+	require.Nil(scheduled)
+}
+
+func TestDeleteExpiredBackupNeverStarted(t *testing.T) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	// Nothing was written to storage for a backup that was cancelled before it started.
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cancelled"},
+		Spec:       api.CassandraBackupSpec{Name: "cancelled", CassandraDatacenter: "missing-dc"},
+		Status: api.CassandraBackupStatus{
+			FinishTime: metav1.Now(),
+			Conditions: []metav1.Condition{{Type: api.BackupConditionFailed, Status: metav1.ConditionTrue}},
+		},
+	}
+	c := newFakeDatacenterClient(t, backup)
+	factory := NewMedusaClientFactory()
+	r := &CassandraBackupScheduleReconciler{Client: c, Log: ctrl.Log, ClientFactory: factory}
+
+	require.NoError(r.deleteExpiredBackup(ctx, backup), "the backup is not purged from storage")
+	require.Empty(factory.GetDeletedBackups())
+	err := c.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name}, &api.CassandraBackup{})
+	require.True(errors.IsNotFound(err), "the expired backup is deleted")
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testBackupSync(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	startTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	finishTime := startTime.Add(10 * time.Minute)
+	medusaClientFactory.setStoredBackups(&pb.BackupSummary{
+		BackupName:    "external-backup",
+		StartTime:     startTime.Unix(),
+		FinishTime:    finishTime.Unix(),
+		TotalNodes:    3,
+		FinishedNodes: 3,
+		Nodes: []*pb.BackupNode{
+			{Host: "10.0.0.2", Datacenter: TestCassandraDatacenterName, Rack: "rack1"},
+			{Host: "10.0.0.1", Datacenter: TestCassandraDatacenterName, Rack: "rack1"},
+			{Host: "10.0.1.1", Datacenter: "old-dc", Rack: "rack1"},
+		},
+	})
+
+	syncKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-sync"}
+	sync := &api.CassandraBackupSync{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: syncKey.Namespace,
+			Name:      syncKey.Name,
+		},
+		Spec: api.CassandraBackupSyncSpec{
+			CassandraDatacenter: TestCassandraDatacenterName,
+			Prune:               true,
+		},
+	}
+
+	t.Log("creating CassandraBackupSync")
+	err := testClient.Create(ctx, sync)
+	require.NoError(err, "failed to create CassandraBackupSync")
+
+	t.Log("verify that the backups in storage are imported")
+	importedKey := types.NamespacedName{Namespace: namespace, Name: "external-backup-" + TestCassandraDatacenterName}
+	require.Eventually(func() bool {
+		imported := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, importedKey, imported); err != nil {
+			return false
+		}
+		return imported.IsSucceeded()
+	}, timeout, interval)
+
+	imported := &api.CassandraBackup{}
+	err = testClient.Get(ctx, importedKey, imported)
+	require.NoError(err, "failed to get imported CassandraBackup")
+	require.True(imported.IsImported())
+	require.Equal(sync.Name, imported.Labels[api.ImportedBackupLabel])
+	require.Equal("external-backup", imported.Spec.Name)
+	require.Equal(TestCassandraDatacenterName, imported.Spec.CassandraDatacenter)
+	require.Equal(api.RetainBackup, imported.Spec.DeletionPolicy, "imported backups are never deleted from storage")
+	require.Empty(imported.Finalizers)
+	require.Equal(api.BackupPhaseSucceeded, imported.Status.Phase)
+	require.True(imported.Status.StartTime.Time.Equal(startTime))
+	require.True(imported.Status.FinishTime.Time.Equal(finishTime))
+	require.Equal([]string{"10.0.0.1", "10.0.0.2"}, imported.Status.Finished)
+	require.NotNil(imported.Status.CassdcTemplateSpec, "the template is taken from the existing datacenter")
+
+	other := &api.CassandraBackup{}
+	err = testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "external-backup-old-dc"}, other)
+	require.NoError(err, "the backup of each datacenter is imported")
+	require.Equal("old-dc", other.Spec.CassandraDatacenter)
+	require.Nil(other.Status.CassdcTemplateSpec)
+
+	t.Log("verify that the backups taken by the operator are not imported again")
+	backups := &api.CassandraBackupList{}
+	err = testClient.List(ctx, backups, client.InNamespace(namespace))
+	require.NoError(err, "failed to list CassandraBackups")
+	taken := make(map[string]bool)
+	for _, backup := range backups.Items {
+		if !backup.IsImported() {
+			taken[backup.Spec.Name+"/"+backup.Spec.CassandraDatacenter] = true
+		}
+	}
+	for _, backup := range backups.Items {
+		if backup.IsImported() {
+			assert.False(t, taken[backup.Spec.Name+"/"+backup.Spec.CassandraDatacenter], "backup %s was imported", backup.Spec.Name)
+		}
+	}
+
+	t.Log("verify that imported backups are not started")
+	for _, requested := range medusaClientFactory.GetRequestedBackups() {
+		assert.NotContains(t, requested, "external-backup")
+	}
+
+	updated := &api.CassandraBackupSync{}
+	err = testClient.Get(ctx, syncKey, updated)
+	require.NoError(err, "failed to get CassandraBackupSync")
+	require.True(meta.IsStatusConditionTrue(updated.Status.Conditions, api.BackupSyncConditionSynced))
+	require.False(updated.Status.LastSyncTime.IsZero())
+	require.GreaterOrEqual(updated.Status.Imported, int32(2))
+
+	t.Log("verify that the backups deleted from storage are pruned")
+	medusaClientFactory.setStoredBackups()
+	patch := client.MergeFrom(updated.DeepCopy())
+	updated.Spec.Interval = &metav1.Duration{Duration: time.Hour}
+	err = testClient.Patch(ctx, updated, patch)
+	require.NoError(err, "failed to patch CassandraBackupSync")
+
+	require.Eventually(func() bool {
+		err := testClient.Get(ctx, importedKey, &api.CassandraBackup{})
+		return errors.IsNotFound(err)
+	}, timeout, interval)
+}
+
+func TestImportedBackupName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("dc1-full-20211115103000-dc1", importedBackupName("dc1-full-20211115103000", "dc1"))
+
+	name := importedBackupName("Backup_2021", "dc1")
+	assert.True(strings.HasPrefix(name, "backup-2021-dc1-"), name)
+	assert.NotEqual(name, importedBackupName("backup-2021", "dc1"), "sanitized names must not collide")
+	assert.NotEqual(name, importedBackupName("BACKUP_2021", "dc1"), "sanitized names must not collide")
+
+	name = importedBackupName(strings.Repeat("a", 300), "dc1")
+	assert.LessOrEqual(len(name), validation.DNS1123SubdomainMaxLength)
+	assert.Empty(validation.IsDNS1123Subdomain(name))
+
+	assert.Empty(validation.IsDNS1123Subdomain(importedBackupName("__", "")))
+}
+
+func TestStoredBackupDatacenters(t *testing.T) {
+	assert := assert.New(t)
+
+	summary := &pb.BackupSummary{
+		BackupName: "backup",
+		Nodes: []*pb.BackupNode{
+			{Host: "10.0.0.1", Datacenter: "dc1"},
+			{Host: "10.0.1.1", Datacenter: "dc2"},
+			{Host: "10.0.0.2"},
+		},
+	}
+	datacenters := storedBackupDatacenters(summary, "dc1")
+	assert.Len(datacenters, 2)
+	assert.Len(datacenters["dc1"], 2)
+	assert.Len(datacenters["dc2"], 1)
+
+	datacenters = storedBackupDatacenters(&pb.BackupSummary{BackupName: "backup"}, "dc1")
+	assert.Contains(datacenters, "dc1", "backups without nodes belong to the default datacenter")
+}
+
+func TestSetImportedBackupStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := []*pb.BackupNode{{Host: "10.0.0.2"}, {Host: "10.0.0.1"}}
+	backup := &api.CassandraBackup{}
+	setImportedBackupStatus(backup, &pb.BackupSummary{StartTime: 1000, TotalNodes: 2, FinishedNodes: 1}, nodes)
+	assert.Equal(api.BackupPhaseRunning, backup.Status.Phase, "incomplete backups are imported as running")
+	assert.True(backup.Status.FinishTime.IsZero())
+	assert.Empty(backup.Status.Finished)
+
+	setImportedBackupStatus(backup, &pb.BackupSummary{StartTime: 1000, FinishTime: 2000, TotalNodes: 2, FinishedNodes: 2}, nodes)
+	assert.Equal(api.BackupPhaseSucceeded, backup.Status.Phase)
+	assert.Equal(int64(1000), backup.Status.StartTime.Unix())
+	assert.Equal(int64(2000), backup.Status.FinishTime.Unix())
+	assert.Equal([]string{"10.0.0.1", "10.0.0.2"}, backup.Status.Finished)
+}
@@ -19,41 +19,72 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/k8ssandra/medusa-operator/pkg/medusa"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
 	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
 	operrors "github.com/k8ssandra/medusa-operator/pkg/errors"
+	"github.com/k8ssandra/medusa-operator/pkg/pb"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
 const (
 	backupSidecarPort = 50051
-	backupSidecarName = "medusa"
+	backupSidecarName = medusa.DefaultSidecarContainerName
+
+	// backupStartTimeout is how long to wait for a sidecar to report a backup that was
+	// started before the pod is considered failed.
+	backupStartTimeout = 2 * time.Minute
+
+	// backupFinalizer is set on CassandraBackups whose DeletionPolicy is Delete, unless they
+	// were imported. It is removed once the backup has been deleted from storage.
+	backupFinalizer = "cassandra.k8ssandra.io/purge-backup"
 )
 
 // CassandraBackupReconciler reconciles a CassandraBackup object
 type CassandraBackupReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 	medusa.ClientFactory
+	// DefaultTLS secures the connections to the sidecars of the datacenters that do not
+	// override it with annotations.
+	DefaultTLS   medusa.TLSSettings
+	RequeueAfter time.Duration
+	// PodExecutor runs the commands of exec hooks in the Cassandra pods.
+	PodExecutor cassandra.PodExecutor
+
+	// tasks are the Backup requests running in the background.
+	tasks backupTasks
+	// hookTasks are the exec hooks running in the background.
+	hookTasks hookTasks
 }
 
 // +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cassandra.datastax.com,namespace="medusa-operator",resources=cassandradatacenters,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=pods;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups=batch,namespace="medusa-operator",resources=jobs,verbs=get;list;watch;create
 
 func (r *CassandraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = r.Log.WithValues("cassandrabackup", req.NamespacedName)
@@ -65,6 +96,8 @@ func (r *CassandraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	if err != nil {
 		r.Log.Error(err, "Failed to get CassandraBackup")
 		if errors.IsNotFound(err) {
+			r.tasks.cancelAll(req.NamespacedName)
+			r.hookTasks.cancelAll(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
@@ -72,42 +105,57 @@ func (r *CassandraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	backup := instance.DeepCopy()
 
-	// If there is anything in progress, simply requeue the request
-	if len(backup.Status.InProgress) > 0 {
-		r.Log.Info("CassandraBackup is being processed already", "Backup", req.NamespacedName)
-		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	if !backup.DeletionTimestamp.IsZero() {
+		r.tasks.cancelAll(req.NamespacedName)
+		r.hookTasks.cancelAll(req.NamespacedName)
+		return r.finalizeBackup(ctx, backup)
+	}
+
+	if err := r.updateFinalizer(ctx, backup); err != nil {
+		r.Log.Error(err, "Failed to update finalizer", "Backup", req.NamespacedName)
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	// The status of imported backups is maintained by the CassandraBackupSync that
+	// imported them.
+	if backup.IsImported() {
+		r.Log.Info("Backup was imported from storage")
+		return ctrl.Result{}, nil
 	}
 
 	// If the backup is already finished, there is nothing to do.
 	if backupFinished(backup) {
 		r.Log.Info("Backup operation is already finished")
+		if backup.IsSucceeded() {
+			lastSuccessfulBackup.set(backup.Namespace, backup.Spec.CassandraDatacenter, backup.Status.FinishTime.Time)
+		}
 		return ctrl.Result{Requeue: false}, nil
 	}
 
-	// First check to see if the backup is already in progress
+	// If the backup is already started, update the status from the sidecars.
 	if !backup.Status.StartTime.IsZero() {
-		// If there is anything in progress, simply requeue the request
-		if len(backup.Status.InProgress) > 0 {
-			r.Log.Info("Backups already in progress")
-			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
-		}
+		return r.syncBackupStatus(ctx, backup)
+	}
 
-		r.Log.Info("backup complete")
+	r.Log.Info("Backups have not been started yet")
 
-		// Set the finish time
-		// Note that the time here is not accurate, but that is ok. For now we are just
-		// using it as a completion marker.
-		patch := client.MergeFrom(backup.DeepCopy())
-		backup.Status.FinishTime = metav1.Now()
-		if err := r.Status().Patch(context.Background(), backup, patch); err != nil {
-			r.Log.Error(err, "failed to patch status with finish time")
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
-		}
+	if backup.Spec.Cancel {
+		return r.cancelPendingBackup(ctx, backup)
+	}
 
-		return ctrl.Result{Requeue: false}, nil
+	if errs := backup.Spec.ValidateKeyspaces(); len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+		r.Log.Info("The keyspaces to back up are not valid", "Reason", message)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidKeyspaces", message)
+		return r.failPendingBackup(ctx, backup, "InvalidKeyspaces", message)
 	}
 
-	r.Log.Info("Backups have not been started yet")
+	if errs := backup.Spec.ValidateHooks(); len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+		r.Log.Info("The hooks are not valid", "Reason", message)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidHooks", message)
+		return r.failPendingBackup(ctx, backup, "InvalidHooks", message)
+	}
 
 	cassdcKey := types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}
 	cassdc := &cassdcapi.CassandraDatacenter{}
@@ -117,29 +165,84 @@ func (r *CassandraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 
-	pods, err := r.getCassandraDatacenterPods(ctx, cassdc)
+	pods, err := cassandra.GetDatacenterPods(ctx, r.Client, cassdc)
 	if err != nil {
 		r.Log.Error(err, "Failed to get datacenter pods")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 
+	dialer, err := newSidecarDialer(ctx, r.Client, cassdc, backup.Spec.Sidecar, r.ClientFactory, r.DefaultTLS)
+	if err != nil {
+		r.Log.Error(err, "Failed to configure the connection to the backup sidecars", "CassandraDatacenter", cassdcKey)
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
 	// Make sure that Medusa is deployed
-	if !isMedusaDeployed(pods) {
-		// TODO generate event and/or update status to indicate error condition
-		r.Log.Error(operrors.BackupSidecarNotFound, "medusa is not deployed", "CassandraDatacenter", cassdcKey)
+	if missing := dialer.podsWithoutSidecar(pods); len(missing) > 0 {
+		r.Log.Error(operrors.BackupSidecarNotFound, "medusa is not deployed", "CassandraDatacenter", cassdcKey, "Pods", missing)
+		message := fmt.Sprintf("Container %s not found in pods %s", dialer.containerName, strings.Join(missing, ", "))
+		r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupSidecarNotFound", "CassandraDatacenter %s: %s", cassdc.Name, message)
+		if err := r.setSidecarNotFoundCondition(ctx, backup, metav1.ConditionTrue, message); err != nil {
+			r.Log.Error(err, "Failed to patch status")
+		}
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, operrors.BackupSidecarNotFound
 	}
 
+	if updated, err := r.ensureBackupName(ctx, backup, pods, dialer); err != nil {
+		r.Log.Error(err, "Failed to set the backup name")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	} else if updated {
+		r.Log.Info("Generated backup name", "Backup", backup.Spec.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if done, err := r.reconcileHooks(ctx, backup, api.PreBackupHook, pods); err != nil || !done {
+		if err != nil {
+			r.Log.Error(err, "Failed to run pre-backup hooks")
+		}
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+	if hook := failedHook(backup, api.PreBackupHook); hook != nil {
+		return r.abortBackup(ctx, backup, pods, hook)
+	}
+
 	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	if err = r.addCassdcSpecToStatus(ctx, backup, cassdc); err != nil {
 		r.Log.Error(err, "failed to patch status with CassdcTemplateSpec", "CassandraDatacenter", cassdcKey)
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 
+	if meta.FindStatusCondition(backup.Status.Conditions, api.BackupConditionSidecarNotFound) != nil {
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:               api.BackupConditionSidecarNotFound,
+			Status:             metav1.ConditionFalse,
+			Reason:             "SidecarFound",
+			Message:            fmt.Sprintf("Container %s found in all pods", dialer.containerName),
+			ObservedGeneration: backup.Generation,
+		})
+	}
 	backup.Status.StartTime = metav1.Now()
+	backup.Status.Keyspaces = backup.Spec.Keyspaces
+	backup.Status.ExcludeKeyspaces = backup.Spec.ExcludeKeyspaces
+	sortPodsByRack(pods, cassdc.Spec.Racks)
 	for _, pod := range pods {
-		backup.Status.InProgress = append(backup.Status.InProgress, pod.Name)
+		backup.Status.Pending = append(backup.Status.Pending, pod.Name)
+		backup.Status.Nodes = append(backup.Status.Nodes, api.NodeBackupStatus{
+			Pod:   pod.Name,
+			Host:  pod.Status.PodIP,
+			Rack:  pod.Labels[cassdcapi.RackLabel],
+			State: api.NodeBackupPending,
+		})
 	}
+	started := startPendingNodes(backup, nil, backup.Status.StartTime.Time)
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionStarted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackupStarted",
+		Message:            fmt.Sprintf("Backup started on %d of %d pods", len(started), len(pods)),
+		ObservedGeneration: backup.Generation,
+	})
+	backup.Status.Phase = computeBackupPhase(backup)
 	r.Log.Info("checking status", "CassandraDatacenterTemplateSpec", backup.Status.CassdcTemplateSpec)
 	if err := r.Status().Patch(context.Background(), backup, patch); err != nil {
 		r.Log.Error(err, "Failed to patch status")
@@ -148,48 +251,445 @@ func (r *CassandraBackupReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 
 	r.Log.Info("Starting backups")
-	// Do the actual backup in the background
+	recordBackupStarted(backup)
+	r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupStarted", "Starting backup %s on %d of %d pods", backup.Spec.Name, len(started), len(pods))
+	for _, podName := range started {
+		r.startBackup(backup, findPod(pods, podName), dialer, 1)
+	}
+
+	return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+}
+
+// startBackup triggers the backup on the pod's sidecar. The Backup RPC blocks until the
+// backup is complete, so it is called in the background, bounded by the node timeout. If it
+// fails, the node is marked as failed so that it can be retried. Otherwise the state of the
+// node is reconciled from the BackupStatus RPC so that it survives operator restarts.
+func (r *CassandraBackupReconciler) startBackup(backup *api.CassandraBackup, pod *corev1.Pod, dialer *sidecarDialer, attempt int32) {
+	backup, pod = backup.DeepCopy(), pod.DeepCopy()
+	key := client.ObjectKeyFromObject(backup)
+	nodeTimeout := durationOrZero(backup.Spec.NodeTimeout)
+	ctx, done := r.tasks.start(key, pod.Name, nodeTimeout)
 	go func() {
-		wg := sync.WaitGroup{}
-
-		// Mutex to prevent concurrent updates to the backup.Status object
-		backupMutex := sync.Mutex{}
-		patch := client.MergeFrom(backup.DeepCopy())
-
-		for _, p := range pods {
-			pod := p
-			wg.Add(1)
-			go func() {
-				r.Log.Info("starting backup", "CassandraPod", pod.Name)
-				succeeded := false
-				if err := doBackup(ctx, backup.Spec.Name, backup.Spec.Type, &pod, r.ClientFactory); err == nil {
-					r.Log.Info("finished backup", "CassandraPod", pod.Name)
-					succeeded = true
+		defer done()
+		r.Log.Info("starting backup", "CassandraPod", pod.Name, "Attempt", attempt)
+		err := doBackup(ctx, backup, pod, dialer)
+		if err == nil {
+			r.Log.Info("finished backup", "CassandraPod", pod.Name)
+			return
+		}
+
+		state := api.NodeBackupFailed
+		switch ctx.Err() {
+		case context.Canceled:
+			// The backup was cancelled or timed out, and the node status already says so.
+			r.Log.Info("backup aborted", "CassandraPod", pod.Name, "Attempt", attempt)
+			return
+		case context.DeadlineExceeded:
+			state = api.NodeBackupTimedOut
+			err = fmt.Errorf("the backup of the node did not finish within %s", nodeTimeout)
+			r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupTimedOut", "Backup timed out on pod %s", pod.Name)
+		default:
+			r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupFailed", "Backup failed on pod %s: %s", pod.Name, err)
+		}
+		r.Log.Error(err, "backup failed", "CassandraPod", pod.Name, "Attempt", attempt)
+		if err := r.setNodeFailed(context.Background(), key, pod.Name, attempt, state, err); err != nil {
+			r.Log.Error(err, "Failed to record the backup error in the status", "CassandraPod", pod.Name)
+		}
+	}()
+}
+
+// setNodeFailed records the error in the status of the node running in the pod and moves
+// the pod to the failed list. Errors of previous attempts are ignored.
+func (r *CassandraBackupReconciler) setNodeFailed(ctx context.Context, key types.NamespacedName, podName string, attempt int32, state api.NodeBackupState, nodeErr error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		backup := &api.CassandraBackup{}
+		if err := r.Get(ctx, key, backup); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		node := backup.Status.GetNode(podName)
+		if node == nil || node.Attempts != attempt || node.State != api.NodeBackupInProgress {
+			return nil
+		}
+		patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		node.LastError = nodeErr.Error()
+		setNodeFinished(backup, podName, state, "", time.Now())
+		backup.Status.InProgress = removeString(backup.Status.InProgress, podName)
+		backup.Status.Failed = append(backup.Status.Failed, podName)
+		return r.Status().Patch(ctx, backup, patch)
+	})
+}
+
+// cancelPendingBackup marks a backup that is cancelled before it started as failed.
+func (r *CassandraBackupReconciler) cancelPendingBackup(ctx context.Context, backup *api.CassandraBackup) (ctrl.Result, error) {
+	r.Log.Info("Backup cancelled before it started", "Backup", backup.Name)
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupCancelled", "Backup cancelled before it started")
+	return r.failPendingBackup(ctx, backup, "BackupCancelled", "The backup was cancelled before it started")
+}
+
+// failPendingBackup marks a backup that has not started as failed and finished, so that it
+// is not considered active anymore.
+func (r *CassandraBackupReconciler) failPendingBackup(ctx context.Context, backup *api.CassandraBackup, reason, message string) (ctrl.Result, error) {
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	backup.Status.FinishTime = metav1.Now()
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionFailed,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+	backup.Status.Phase = computeBackupPhase(backup)
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		r.Log.Error(err, "Failed to patch status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+	recordBackupFinished(backup)
+	return ctrl.Result{}, nil
+}
+
+// syncBackupStatus queries the backup status from one of the sidecars and moves the pods
+// that are in progress to the finished or failed lists accordingly. Pods that the sidecar
+// does not know about are considered failed once backupStartTimeout has passed, and pods
+// that exceed the node timeout are marked as timed out. Pods are left in progress, and
+// failed pods are not retried, while the status cannot be queried. Failed pods are retried
+// according to the retry policy, unless the backup is cancelled or timed out in which case
+// all the pods in progress or pending are stopped. Pending pods are started as soon as the
+// concurrency limit and the rack order allow it. The finish time is set once no pods are
+// pending, in progress or waiting to be retried anymore.
+func (r *CassandraBackupReconciler) syncBackupStatus(ctx context.Context, backup *api.CassandraBackup) (ctrl.Result, error) {
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	policy := newRetryPolicy(backup.Spec.RetryPolicy)
+	retryPending := make([]string, 0)
+	var podsFinished []string
+	var pods, retries, started []corev1.Pod
+	var dialer *sidecarDialer
+	var err error
+
+	if len(backup.Status.InProgress) > 0 || len(backup.Status.Pending) > 0 || policy.hasRetriableNodes(backup) {
+		cassdcKey := types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}
+		cassdc := &cassdcapi.CassandraDatacenter{}
+		if err := r.Get(ctx, cassdcKey, cassdc); err != nil {
+			r.Log.Error(err, "failed to get cassandradatacenter", "CassandraDatacenter", cassdcKey)
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+
+		pods, err = cassandra.GetDatacenterPods(ctx, r.Client, cassdc)
+		if err != nil {
+			r.Log.Error(err, "Failed to get datacenter pods")
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+
+		dialer, err = newSidecarDialer(ctx, r.Client, cassdc, backup.Spec.Sidecar, r.ClientFactory, r.DefaultTLS)
+		if err != nil {
+			r.Log.Error(err, "Failed to configure the connection to the backup sidecars")
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+
+		status, err := getBackupStatus(ctx, backup.Spec.Name, pods, dialer)
+		statusUnavailable := false
+		if err != nil {
+			// The sidecars may not have registered the backup yet, in which case the nodes
+			// are missing. Any other error, such as a sidecar that restarts, leaves the state
+			// of the nodes unknown until the next reconciliation.
+			statusUnavailable = backupStatusUnavailable(err)
+			r.Log.Info("Failed to get backup status", "Backup", backup.Name, "Error", err.Error())
+		}
+
+		now := time.Now()
+		stopState, stopReason := backupStopState(backup, now)
+		nodeTimeout := durationOrZero(backup.Spec.NodeTimeout)
+		inProgress := backup.Status.InProgress
+		backup.Status.InProgress = make([]string, 0, len(inProgress))
+		for _, podName := range inProgress {
+			nodeState := getNodeBackupState(status, findPod(pods, podName))
+			switch {
+			case nodeState == nodeBackupFinished:
+				backup.Status.Finished = append(backup.Status.Finished, podName)
+				setNodeFinished(backup, podName, api.NodeBackupFinished, "", now)
+				podsFinished = append(podsFinished, podName)
+			case len(stopState) > 0:
+				r.Log.Info("Stopping backup", "CassandraPod", podName, "State", stopState)
+				r.tasks.cancel(client.ObjectKeyFromObject(backup), podName)
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+				stopNode(backup, podName, stopState, stopReason, now)
+			case nodeTimeout > 0 && now.Sub(nodeStartTime(backup, podName).Time) > nodeTimeout:
+				r.Log.Info("Backup timed out on pod", "CassandraPod", podName)
+				r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupTimedOut", "Backup timed out on pod %s", podName)
+				r.tasks.cancel(client.ObjectKeyFromObject(backup), podName)
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+				stopNode(backup, podName, api.NodeBackupTimedOut,
+					fmt.Sprintf("the backup of the node did not finish within %s", nodeTimeout), now)
+			case nodeState == nodeBackupUnfinished || statusUnavailable:
+				backup.Status.InProgress = append(backup.Status.InProgress, podName)
+			default:
+				if now.Sub(nodeStartTime(backup, podName).Time) > backupStartTimeout {
+					r.Log.Info("Backup did not start on pod", "CassandraPod", podName)
+					r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupFailed", "Backup did not start on pod %s", podName)
+					r.tasks.cancel(client.ObjectKeyFromObject(backup), podName)
+					backup.Status.Failed = append(backup.Status.Failed, podName)
+					setNodeFinished(backup, podName, api.NodeBackupFailed,
+						fmt.Sprintf("the sidecar did not report the backup within %s", backupStartTimeout), now)
 				} else {
-					r.Log.Error(err, "backup failed", "CassandraPod", pod.Name)
+					backup.Status.InProgress = append(backup.Status.InProgress, podName)
 				}
-				backupMutex.Lock()
-				defer backupMutex.Unlock()
-				defer wg.Done()
-				backup.Status.InProgress = removeValue(backup.Status.InProgress, pod.Name)
-				if succeeded {
-					backup.Status.Finished = append(backup.Status.Finished, pod.Name)
+			}
+		}
+
+		failed := backup.Status.Failed
+		backup.Status.Failed = make([]string, 0, len(failed))
+		for _, podName := range failed {
+			pod, node := findPod(pods, podName), backup.Status.GetNode(podName)
+			switch {
+			case node == nil || pod == nil || node.Attempts >= policy.maxAttempts:
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+			case getNodeBackupState(status, pod) == nodeBackupFinished:
+				// The Backup RPC failed but the sidecar finished the backup anyway.
+				backup.Status.Finished = append(backup.Status.Finished, podName)
+				setNodeFinished(backup, podName, api.NodeBackupFinished, "", now)
+				podsFinished = append(podsFinished, podName)
+			case len(stopState) > 0:
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+			case statusUnavailable || now.Before(node.FinishTime.Add(policy.backoff(node.Attempts))):
+				// The sidecar may have finished the backup, which is only known once the
+				// status is available again.
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+				retryPending = append(retryPending, podName)
+			default:
+				r.Log.Info("Retrying backup", "CassandraPod", podName, "Attempt", node.Attempts+1)
+				node.Attempts++
+				node.State = api.NodeBackupInProgress
+				node.StartTime = metav1.NewTime(now)
+				node.FinishTime = metav1.Time{}
+				backup.Status.InProgress = append(backup.Status.InProgress, podName)
+				retries = append(retries, *pod)
+			}
+		}
+
+		if len(stopState) > 0 {
+			for _, podName := range backup.Status.Pending {
+				backup.Status.Failed = append(backup.Status.Failed, podName)
+				stopNode(backup, podName, stopState, stopReason, now)
+			}
+			backup.Status.Pending = nil
+		} else {
+			busy := append(append([]string{}, backup.Status.InProgress...), retryPending...)
+			for _, podName := range startPendingNodes(backup, busy, now) {
+				if pod := findPod(pods, podName); pod != nil {
+					started = append(started, *pod)
 				} else {
-					backup.Status.Failed = append(backup.Status.Failed, pod.Name)
+					backup.Status.InProgress = removeString(backup.Status.InProgress, podName)
+					backup.Status.Failed = append(backup.Status.Failed, podName)
+					setNodeFinished(backup, podName, api.NodeBackupFailed, "the pod was not found", now)
 				}
-			}()
+			}
+		}
+	}
+
+	nodesDone := len(backup.Status.InProgress) == 0 && len(backup.Status.Pending) == 0 && len(retryPending) == 0
+	hooksDone := true
+	var startedHooks []api.BackupHook
+	if nodesDone && len(backupHooks(backup, api.PostBackupHook)) > 0 {
+		if pods == nil {
+			if pods, err = r.getBackupPods(ctx, backup); err != nil {
+				r.Log.Error(err, "Failed to get datacenter pods")
+				return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+			}
 		}
-		wg.Wait()
-		r.Log.Info("finished backup operations")
-		if err := r.Status().Patch(context.Background(), backup, patch); err != nil {
-			r.Log.Error(err, "failed to patch status", "Backup", fmt.Sprintf("%s/%s", backup.Name, backup.Namespace))
+		if hooksDone, startedHooks, err = r.runHooks(ctx, backup, api.PostBackupHook); err != nil {
+			r.Log.Error(err, "Failed to run post-backup hooks")
 		}
-	}()
+	}
+
+	if nodesDone && hooksDone {
+		r.Log.Info("backup complete")
+		backup.Status.FinishTime = metav1.Now()
+		if summary, err := r.getBackupSummary(ctx, backup, pods, dialer); err != nil {
+			// The summary is informative, the backup is done either way.
+			r.Log.Info("Failed to get the backup summary from storage", "Backup", backup.Spec.Name, "Error", err.Error())
+		} else {
+			setBackupSummary(backup, summary)
+		}
+		setBackupCompletedConditions(backup)
+		if backup.Spec.Cancel {
+			r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupCancelled", "Backup %s cancelled, finished on %d pods", backup.Spec.Name, len(backup.Status.Finished))
+		} else if backup.IsFailed() {
+			r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupFailed", "Backup %s failed on %d pods", backup.Spec.Name, len(backup.Status.Failed))
+		} else {
+			r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupSucceeded", "Backup %s finished on %d pods", backup.Spec.Name, len(backup.Status.Finished))
+		}
+	}
+	backup.Status.Phase = computeBackupPhase(backup)
+
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		r.Log.Error(err, "failed to patch status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	// The metrics, hooks and retries are only recorded and started once they have been
+	// persisted.
+	for _, podName := range podsFinished {
+		recordPodBackupFinished(backup, podName)
+	}
+	if nodesDone {
+		r.startHooks(backup, api.PostBackupHook, startedHooks, pods)
+	}
+	for i := range retries {
+		attempt := backup.Status.GetNode(retries[i].Name).Attempts
+		r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupRetried", "Retrying backup on pod %s, attempt %d of %d", retries[i].Name, attempt, policy.maxAttempts)
+		r.startBackup(backup, &retries[i], dialer, attempt)
+	}
+	for i := range started {
+		r.Log.Info("Starting backup of pending pod", "CassandraPod", started[i].Name)
+		r.startBackup(backup, &started[i], dialer, 1)
+	}
+
+	if backupFinished(backup) {
+		recordBackupFinished(backup)
+		return ctrl.Result{Requeue: false}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+}
+
+// ensureBackupName generates the backup name when the defaulting webhook did not, and makes
+// sure that generated names do not collide with the backups in storage by appending a
+// suffix. Names set by the user are left untouched. It returns true if the CassandraBackup
+// has been updated.
+func (r *CassandraBackupReconciler) ensureBackupName(ctx context.Context, backup *api.CassandraBackup, pods []corev1.Pod, dialer *sidecarDialer) (bool, error) {
+	if len(backup.Spec.Name) > 0 && !metav1.HasAnnotation(backup.ObjectMeta, api.GeneratedBackupNameAnnotation) {
+		return false, nil
+	}
+
+	original := backup.DeepCopy()
+	if len(backup.Spec.Type) == 0 {
+		backup.Spec.Type = api.DifferentialBackup
+	}
+	if len(backup.Spec.Name) == 0 {
+		name, err := api.GenerateBackupName(backup, backup.CreationTimestamp.Time)
+		if err != nil {
+			return false, err
+		}
+		backup.Spec.Name = name
+		metav1.SetMetaDataAnnotation(&backup.ObjectMeta, api.GeneratedBackupNameAnnotation, "true")
+	}
+
+	existing, err := getStoredBackupNames(ctx, pods, dialer)
+	if err != nil {
+		return false, err
+	}
+	backup.Spec.Name = uniqueBackupName(backup.Spec.Name, existing)
+
+	if backup.Spec.Name == original.Spec.Name && backup.Spec.Type == original.Spec.Type {
+		return false, nil
+	}
+	return true, r.Patch(ctx, backup, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
+
+// uniqueBackupName returns name, with a numeric suffix if needed to make it unique.
+func uniqueBackupName(name string, existing map[string]bool) string {
+	unique := name
+	for i := 1; existing[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	return unique
+}
+
+// setSidecarNotFoundCondition patches the status with the SidecarNotFound condition.
+func (r *CassandraBackupReconciler) setSidecarNotFoundCondition(ctx context.Context, backup *api.CassandraBackup, status metav1.ConditionStatus, message string) error {
+	patch := client.MergeFrom(backup.DeepCopy())
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionSidecarNotFound,
+		Status:             status,
+		Reason:             "SidecarNotFound",
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+	return r.Status().Patch(ctx, backup, patch)
+}
+
+// updateFinalizer adds or removes the finalizer according to the DeletionPolicy. Imported
+// backups never get it since they are never deleted from storage.
+func (r *CassandraBackupReconciler) updateFinalizer(ctx context.Context, backup *api.CassandraBackup) error {
+	purge := backup.Spec.DeletionPolicy == api.DeleteBackup && !backup.IsImported()
+	if purge == controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		return nil
+	}
+
+	patch := client.MergeFrom(backup.DeepCopy())
+	if purge {
+		controllerutil.AddFinalizer(backup, backupFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(backup, backupFinalizer)
+	}
+	return r.Patch(ctx, backup, patch)
+}
 
-	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+// finalizeBackup deletes the backup from storage if required by the DeletionPolicy and then
+// removes the finalizer. The finalizer is kept as long as the purge fails.
+func (r *CassandraBackupReconciler) finalizeBackup(ctx context.Context, backup *api.CassandraBackup) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if backup.Spec.DeletionPolicy == api.DeleteBackup && !backup.IsImported() && !backup.Status.StartTime.IsZero() {
+		if !backupFinished(backup) {
+			r.Log.Info("Cancelling backup before deleting it from storage", "Backup", backup.Name)
+			backup.Spec.Cancel = true
+			return r.syncBackupStatus(ctx, backup)
+		}
+
+		r.Log.Info("Deleting backup from storage", "Backup", backup.Name)
+		if err := purgeBackup(ctx, r.Client, backup, r.ClientFactory, r.DefaultTLS); err == operrors.DatacenterNotFound {
+			// Retrying does not help until the datacenter is recreated, which does not
+			// trigger a reconciliation of the backup.
+			message := fmt.Sprintf("CassandraDatacenter %s was not found, so backup %s cannot be deleted from storage. "+
+				"Recreate the datacenter and update the CassandraBackup to retry, or remove the %s finalizer to delete the CassandraBackup without deleting the backup from storage",
+				backup.Spec.CassandraDatacenter, backup.Spec.Name, backupFinalizer)
+			r.Log.Info("Cannot delete backup from storage", "Backup", backup.Name, "Reason", message)
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "DatacenterNotFound", message)
+			r.setPurgeFailed(ctx, backup, "DatacenterNotFound", message)
+			return ctrl.Result{}, nil
+		} else if err != nil {
+			r.Log.Error(err, "Failed to delete backup from storage", "Backup", backup.Name)
+			r.Recorder.Eventf(backup, corev1.EventTypeWarning, "PurgeFailed", "Failed to delete backup %s from storage: %s", backup.Spec.Name, err)
+			r.setPurgeFailed(ctx, backup, "PurgeFailed", err.Error())
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+		}
+	}
+
+	patch := client.MergeFrom(backup.DeepCopy())
+	controllerutil.RemoveFinalizer(backup, backupFinalizer)
+	if err := r.Patch(ctx, backup, patch); err != nil {
+		r.Log.Error(err, "Failed to remove finalizer", "Backup", backup.Name)
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// setPurgeFailed sets the Purged condition to false with the given reason. An error to patch
+// the status is only logged since the purge is retried anyway.
+func (r *CassandraBackupReconciler) setPurgeFailed(ctx context.Context, backup *api.CassandraBackup, reason, message string) {
+	patch := client.MergeFrom(backup.DeepCopy())
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionPurged,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		r.Log.Error(err, "Failed to patch status with purge condition", "Backup", backup.Name)
+	}
 }
 
 func (r *CassandraBackupReconciler) addCassdcSpecToStatus(ctx context.Context, backup *api.CassandraBackup, cassdc *cassdcapi.CassandraDatacenter) error {
+	backup.Status.CassdcTemplateSpec = newCassdcTemplateSpec(cassdc)
+	return nil
+}
+
+// newCassdcTemplateSpec returns the template from which a datacenter like cassdc can be
+// recreated by a restore.
+func newCassdcTemplateSpec(cassdc *cassdcapi.CassandraDatacenter) *api.CassandraDatacenterTemplateSpec {
 	templateSpec := api.CassandraDatacenterTemplateSpec{
 		// TODO The following properties need to be configurable for accessing and managing the cluster:
 		//      * ManagementApiAuth
@@ -231,83 +731,411 @@ func (r *CassandraBackupReconciler) addCassdcSpecToStatus(ctx context.Context, b
 			// TODO set Reaper
 		},
 	}
+	// Keep the Medusa annotations so that a datacenter restored from the template is
+	// configured the same way.
+	templateSpec.Annotations = medusaAnnotations(cassdc)
 
-	backup.Status.CassdcTemplateSpec = &templateSpec
-	return nil
+	return &templateSpec
+}
+
+// doBackup backs up the keyspaces recorded in the status of the backup on the pod.
+func doBackup(ctx context.Context, backup *api.CassandraBackup, pod *corev1.Pod, dialer *sidecarDialer) error {
+	if medusaClient, err := dialer.dial(pod); err != nil {
+		return err
+	} else {
+		defer medusaClient.Close()
+		return medusaClient.CreateBackup(ctx, backup.Spec.Name, string(backup.Spec.Type), backup.Status.Keyspaces, backup.Status.ExcludeKeyspaces)
+	}
 }
 
-func (r *CassandraBackupReconciler) getCassandraDatacenterPods(ctx context.Context, cassdc *cassdcapi.CassandraDatacenter) ([]corev1.Pod, error) {
-	cassdcSvc := &corev1.Service{}
-	err := r.Get(ctx, types.NamespacedName{Namespace: cassdc.Namespace, Name: cassdc.GetAllPodsServiceName()}, cassdcSvc)
+// purgeBackup deletes the backup from the Medusa storage backend. The request only needs to
+// be handled by a single sidecar, so the pods of the backed up datacenter are tried in
+// turn until one of them succeeds. A backup that is not in storage, such as a backup that
+// failed on all nodes before the sidecars registered it, is already purged.
+func purgeBackup(ctx context.Context, c client.Client, backup *api.CassandraBackup, clientFactory medusa.ClientFactory, defaultTLS medusa.TLSSettings) error {
+	cassdcKey := types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}
+	cassdc := &cassdcapi.CassandraDatacenter{}
+	if err := c.Get(ctx, cassdcKey, cassdc); err != nil {
+		if errors.IsNotFound(err) {
+			return operrors.DatacenterNotFound
+		}
+		return err
+	}
+
+	pods, err := cassandra.GetDatacenterPods(ctx, c, cassdc)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	podList := &corev1.PodList{}
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: cassdcSvc.Spec.Selector})
-	listOpts := []client.ListOption{
-		client.MatchingLabelsSelector{
-			Selector: selector,
-		},
+	dialer, err := newSidecarDialer(ctx, c, cassdc, backup.Spec.Sidecar, clientFactory, defaultTLS)
+	if err != nil {
+		return err
 	}
 
-	if err := r.List(context.Background(), podList, listOpts...); err != nil {
-		r.Log.Error(err, "failed to get pods for cassandradatacenter", "CassandraDatacenter", cassdc.Name)
-		return nil, err
+	stored, err := getStoredBackupNames(ctx, pods, dialer)
+	if err != nil {
+		return err
+	}
+	if !stored[backup.Spec.Name] {
+		return nil
 	}
 
-	pods := make([]corev1.Pod, 0)
-	for _, pod := range podList.Items {
-		pods = append(pods, pod)
+	err = operrors.BackupSidecarNotFound
+	for i := range pods {
+		if !dialer.hasSidecar(&pods[i]) {
+			continue
+		}
+		if err = doDeleteBackup(ctx, backup.Spec.Name, &pods[i], dialer); err == nil || medusa.IsNotFound(err) {
+			return nil
+		}
 	}
+	return err
+}
 
-	return pods, nil
+// getStoredBackupNames returns the names of the backups in storage, as reported by the
+// first sidecar that responds.
+func getStoredBackupNames(ctx context.Context, pods []corev1.Pod, dialer *sidecarDialer) (map[string]bool, error) {
+	backups, err := getStoredBackups(ctx, pods, dialer)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		names[backup.BackupName] = true
+	}
+	return names, nil
 }
 
-func isMedusaDeployed(pods []corev1.Pod) bool {
-	for _, pod := range pods {
-		if !hasMedusaSidecar(&pod) {
-			return false
+// getStoredBackups lists the backups in storage from the first sidecar that responds.
+func getStoredBackups(ctx context.Context, pods []corev1.Pod, dialer *sidecarDialer) ([]*pb.BackupSummary, error) {
+	err := operrors.BackupSidecarNotFound
+	for i := range pods {
+		if !dialer.hasSidecar(&pods[i]) {
+			continue
+		}
+		var medusaClient medusa.Client
+		if medusaClient, err = dialer.dial(&pods[i]); err != nil {
+			continue
 		}
+		var backups []*pb.BackupSummary
+		backups, err = medusaClient.GetBackups(ctx)
+		medusaClient.Close()
+		if err != nil {
+			continue
+		}
+		return backups, nil
+	}
+	return nil, err
+}
+
+func doDeleteBackup(ctx context.Context, name string, pod *corev1.Pod, dialer *sidecarDialer) error {
+	if medusaClient, err := dialer.dial(pod); err != nil {
+		return err
+	} else {
+		defer medusaClient.Close()
+		return medusaClient.DeleteBackup(ctx, name)
 	}
-	return true
 }
 
-func hasMedusaSidecar(pod *corev1.Pod) bool {
-	for _, container := range pod.Spec.Containers {
-		if container.Name == backupSidecarName {
-			return true
+// getBackupStatus queries the status of the backup from the first sidecar that responds.
+// Each sidecar reports the status for all nodes of the cluster.
+func getBackupStatus(ctx context.Context, name string, pods []corev1.Pod, dialer *sidecarDialer) (*pb.BackupStatusResponse, error) {
+	err := operrors.BackupSidecarNotFound
+	for i := range pods {
+		if !dialer.hasSidecar(&pods[i]) {
+			continue
+		}
+		var status *pb.BackupStatusResponse
+		if status, err = doBackupStatus(ctx, name, &pods[i], dialer); err == nil {
+			return status, nil
 		}
 	}
-	return false
+	return nil, err
 }
 
-func doBackup(ctx context.Context, name string, backupType api.BackupType, pod *corev1.Pod, clientFactory medusa.ClientFactory) error {
-	addr := fmt.Sprintf("%s:%d", pod.Status.PodIP, backupSidecarPort)
-	if medusaClient, err := clientFactory.NewClient(addr); err != nil {
-		return err
+func doBackupStatus(ctx context.Context, name string, pod *corev1.Pod, dialer *sidecarDialer) (*pb.BackupStatusResponse, error) {
+	if medusaClient, err := dialer.dial(pod); err != nil {
+		return nil, err
 	} else {
 		defer medusaClient.Close()
-		return medusaClient.CreateBackup(ctx, name, string(backupType))
+		return medusaClient.BackupStatus(ctx, name)
 	}
 }
 
-func backupFinished(backup *api.CassandraBackup) bool {
-	return !backup.Status.FinishTime.IsZero()
+// backupStatusUnavailable returns true if the error returned by getBackupStatus leaves the
+// state of the nodes unknown, as opposed to sidecars that do not know the backup.
+func backupStatusUnavailable(err error) bool {
+	return err != nil && err != operrors.BackupSidecarNotFound && !medusa.IsNotFound(err)
+}
+
+type nodeBackupState int
+
+const (
+	nodeBackupMissing nodeBackupState = iota
+	nodeBackupUnfinished
+	nodeBackupFinished
+)
+
+// getNodeBackupState returns the state of the backup for the node running in pod. The node
+// is missing if either status or pod is nil.
+func getNodeBackupState(status *pb.BackupStatusResponse, pod *corev1.Pod) nodeBackupState {
+	if status == nil || pod == nil {
+		return nodeBackupMissing
+	}
+	for _, host := range status.FinishedNodes {
+		if isPodHost(pod, host) {
+			return nodeBackupFinished
+		}
+	}
+	for _, host := range status.UnfinishedNodes {
+		if isPodHost(pod, host) {
+			return nodeBackupUnfinished
+		}
+	}
+	return nodeBackupMissing
+}
+
+// isPodHost returns true if host, as reported by Medusa, refers to pod. Medusa reports
+// either the IP address or the fully qualified host name of the node.
+func isPodHost(pod *corev1.Pod, host string) bool {
+	return host == pod.Status.PodIP || host == pod.Name || strings.HasPrefix(host, pod.Name+".")
+}
+
+// abortBackup runs the post-backup hooks of a backup whose pre-backup hooks failed, and then
+// marks it as failed without starting it.
+func (r *CassandraBackupReconciler) abortBackup(ctx context.Context, backup *api.CassandraBackup, pods []corev1.Pod, hook *api.BackupHookStatus) (ctrl.Result, error) {
+	message := fmt.Sprintf("Pre-backup hook %s failed: %s", hook.Name, hook.Message)
+	if done, err := r.reconcileHooks(ctx, backup, api.PostBackupHook, pods); err != nil || !done {
+		if err != nil {
+			r.Log.Error(err, "Failed to run post-backup hooks")
+		}
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+
+	r.Log.Info("Backup aborted", "Reason", message)
+	r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", message)
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	backup.Status.FinishTime = metav1.Now()
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionFailed,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PreBackupHookFailed",
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+	backup.Status.Phase = computeBackupPhase(backup)
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		r.Log.Error(err, "Failed to patch status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+	recordBackupFinished(backup)
+	return ctrl.Result{}, nil
+}
+
+// getBackupPods returns the pods of the backed up datacenter.
+func (r *CassandraBackupReconciler) getBackupPods(ctx context.Context, backup *api.CassandraBackup) ([]corev1.Pod, error) {
+	cassdc := &cassdcapi.CassandraDatacenter{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}, cassdc); err != nil {
+		return nil, err
+	}
+	return cassandra.GetDatacenterPods(ctx, r.Client, cassdc)
+}
+
+// getBackupSummary returns the summary of the backup in storage. The pods and dialer are
+// looked up when nil.
+func (r *CassandraBackupReconciler) getBackupSummary(ctx context.Context, backup *api.CassandraBackup, pods []corev1.Pod, dialer *sidecarDialer) (*pb.BackupSummary, error) {
+	if dialer == nil {
+		cassdc := &cassdcapi.CassandraDatacenter{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}, cassdc); err != nil {
+			return nil, err
+		}
+		var err error
+		if pods, err = cassandra.GetDatacenterPods(ctx, r.Client, cassdc); err != nil {
+			return nil, err
+		}
+		if dialer, err = newSidecarDialer(ctx, r.Client, cassdc, backup.Spec.Sidecar, r.ClientFactory, r.DefaultTLS); err != nil {
+			return nil, err
+		}
+	}
+
+	summaries, err := getStoredBackups(ctx, pods, dialer)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		if summary.BackupName == backup.Spec.Name {
+			return summary, nil
+		}
+	}
+	return nil, fmt.Errorf("backup %s not found in storage", backup.Spec.Name)
+}
+
+// setBackupSummary records the summary of the backup in storage in the status. The finish
+// time recorded in storage replaces the one observed by the operator when the backup is
+// complete.
+func setBackupSummary(backup *api.CassandraBackup, summary *pb.BackupSummary) {
+	backup.Status.Summary = newBackupSummary(summary)
+	if !backup.Status.Summary.FinishTime.IsZero() {
+		backup.Status.FinishTime = backup.Status.Summary.FinishTime
+	}
+}
+
+func newBackupSummary(summary *pb.BackupSummary) *api.BackupSummary {
+	result := &api.BackupSummary{
+		TotalNodes:    summary.TotalNodes,
+		FinishedNodes: summary.FinishedNodes,
+	}
+	if summary.StartTime > 0 {
+		result.StartTime = metav1.Unix(summary.StartTime, 0)
+	}
+	if summary.FinishTime > 0 {
+		result.FinishTime = metav1.Unix(summary.FinishTime, 0)
+	}
+	for _, node := range summary.Nodes {
+		result.Nodes = append(result.Nodes, api.BackupNode{
+			Host:       node.Host,
+			Datacenter: node.Datacenter,
+			Rack:       node.Rack,
+			Tokens:     append([]int64(nil), node.Tokens...),
+		})
+	}
+	return result
+}
+
+// backupStopState returns the state of the nodes that are stopped because the backup has
+// been cancelled or has timed out, and the reason why. It returns an empty state while the
+// backup can go on.
+func backupStopState(backup *api.CassandraBackup, now time.Time) (api.NodeBackupState, string) {
+	if backup.Spec.Cancel {
+		return api.NodeBackupCancelled, "the backup was cancelled"
+	}
+	if timeout := durationOrZero(backup.Spec.Timeout); timeout > 0 && now.Sub(backup.Status.StartTime.Time) > timeout {
+		return api.NodeBackupTimedOut, fmt.Sprintf("the backup did not finish within %s", timeout)
+	}
+	return "", ""
+}
+
+// stopNode marks the backup of the node running in the pod as cancelled or timed out. The
+// reason replaces the errors of previous attempts.
+func stopNode(backup *api.CassandraBackup, podName string, state api.NodeBackupState, reason string, now time.Time) {
+	if node := backup.Status.GetNode(podName); node != nil {
+		node.LastError = reason
+	}
+	setNodeFinished(backup, podName, state, reason, now)
+}
+
+func durationOrZero(d *metav1.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.Duration
+}
+
+// nodeStartTime returns the time at which the current attempt to back up the node running
+// in the pod started.
+func nodeStartTime(backup *api.CassandraBackup, podName string) metav1.Time {
+	if node := backup.Status.GetNode(podName); node != nil && !node.StartTime.IsZero() {
+		return node.StartTime
+	}
+	return backup.Status.StartTime
+}
+
+// setNodeFinished sets the final state of the backup of the node running in the pod. The
+// reason is only recorded if the sidecar did not report an error already.
+func setNodeFinished(backup *api.CassandraBackup, podName string, state api.NodeBackupState, reason string, now time.Time) {
+	node := backup.Status.GetNode(podName)
+	if node == nil {
+		return
+	}
+	node.State = state
+	node.FinishTime = metav1.NewTime(now)
+	if len(node.LastError) == 0 {
+		node.LastError = reason
+	}
+}
+
+func findPod(pods []corev1.Pod, name string) *corev1.Pod {
+	for i := range pods {
+		if pods[i].Name == name {
+			return &pods[i]
+		}
+	}
+	return nil
+}
+
+// setBackupCompletedConditions sets the Succeeded and Failed conditions of a backup for
+// which no pods are in progress anymore.
+func setBackupCompletedConditions(backup *api.CassandraBackup) {
+	succeeded := metav1.Condition{
+		Type:               api.BackupConditionSucceeded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackupSucceeded",
+		Message:            fmt.Sprintf("Backup finished on %d pods", len(backup.Status.Finished)),
+		ObservedGeneration: backup.Generation,
+	}
+	failed := metav1.Condition{
+		Type:               api.BackupConditionFailed,
+		Status:             metav1.ConditionFalse,
+		Reason:             "BackupSucceeded",
+		ObservedGeneration: backup.Generation,
+	}
+
+	if len(backup.Status.Failed) > 0 {
+		reason := backupFailureReason(backup)
+		succeeded.Status = metav1.ConditionFalse
+		succeeded.Reason = reason
+		failed.Status = metav1.ConditionTrue
+		failed.Reason = reason
+		failed.Message = fmt.Sprintf("Backup failed on pods: %s", strings.Join(backup.Status.Failed, ", "))
+		succeeded.Message = failed.Message
+	} else if hook := failedHook(backup, api.PostBackupHook); hook != nil {
+		succeeded.Status = metav1.ConditionFalse
+		succeeded.Reason = "PostBackupHookFailed"
+		failed.Status = metav1.ConditionTrue
+		failed.Reason = "PostBackupHookFailed"
+		failed.Message = fmt.Sprintf("Post-backup hook %s failed: %s", hook.Name, hook.Message)
+		succeeded.Message = failed.Message
+	}
+
+	meta.SetStatusCondition(&backup.Status.Conditions, succeeded)
+	meta.SetStatusCondition(&backup.Status.Conditions, failed)
 }
 
-func removeValue(slice []string, value string) []string {
-	newSlice := make([]string, 0)
-	for _, s := range slice {
-		if s != value {
-			newSlice = append(newSlice, s)
+// backupFailureReason returns the reason of the Failed condition of a backup that failed on
+// some pods.
+func backupFailureReason(backup *api.CassandraBackup) string {
+	if backup.Spec.Cancel {
+		return "BackupCancelled"
+	}
+	for _, podName := range backup.Status.Failed {
+		if node := backup.Status.GetNode(podName); node != nil && node.State == api.NodeBackupTimedOut {
+			return "BackupTimedOut"
 		}
 	}
-	return newSlice
+	return "BackupFailed"
+}
+
+// computeBackupPhase summarizes the conditions of the backup.
+func computeBackupPhase(backup *api.CassandraBackup) api.BackupPhase {
+	switch {
+	case backup.IsFailed():
+		return api.BackupPhaseFailed
+	case backup.IsSucceeded():
+		return api.BackupPhaseSucceeded
+	case meta.IsStatusConditionTrue(backup.Status.Conditions, api.BackupConditionStarted):
+		return api.BackupPhaseRunning
+	default:
+		return api.BackupPhasePending
+	}
+}
+
+func backupFinished(backup *api.CassandraBackup) bool {
+	return !backup.Status.FinishTime.IsZero()
 }
 
 func (r *CassandraBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.CassandraBackup{}).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
@@ -0,0 +1,273 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+)
+
+// CassandraBackupScheduleReconciler reconciles a CassandraBackupSchedule object
+type CassandraBackupScheduleReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Clock    clock.PassiveClock
+	medusa.ClientFactory
+	DefaultTLS medusa.TLSSettings
+}
+
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackupschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackupschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=events,verbs=create;patch
+
+func (r *CassandraBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("cassandrabackupschedule", req.NamespacedName)
+
+	schedule := &api.CassandraBackupSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get CassandraBackupSchedule")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.CronSchedule)
+	if err != nil {
+		// There is no point in requeueing until the schedule is fixed, which triggers a
+		// new reconciliation.
+		log.Error(err, "Failed to parse cron schedule", "CronSchedule", schedule.Spec.CronSchedule)
+		r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "InvalidCronSchedule", "Invalid cron schedule %q: %s", schedule.Spec.CronSchedule, err)
+		return ctrl.Result{}, nil
+	}
+
+	if len(schedule.Spec.BackupSpec.Name) > 0 {
+		// Every scheduled backup would otherwise be stored under the same name.
+		message := fmt.Sprintf("backupSpec.name must be empty, the name of each scheduled backup is generated but it is set to %s", schedule.Spec.BackupSpec.Name)
+		log.Info("Invalid backup template", "Reason", message)
+		r.Recorder.Event(schedule, corev1.EventTypeWarning, "InvalidBackupSpec", message)
+		return ctrl.Result{}, nil
+	}
+
+	backups := &api.CassandraBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(schedule.Namespace), client.MatchingLabels{api.BackupScheduleLabel: schedule.Name}); err != nil {
+		log.Error(err, "Failed to list CassandraBackups")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	patch := client.MergeFrom(schedule.DeepCopy())
+	active, lastSuccessful := summarizeScheduledBackups(backups.Items)
+	schedule.Status.Active = make([]string, 0, len(active))
+	for _, backup := range active {
+		schedule.Status.Active = append(schedule.Status.Active, backup.Name)
+	}
+	if lastSuccessful != nil {
+		schedule.Status.LastSuccessfulBackup = lastSuccessful.Name
+	}
+
+	now := r.Clock.Now()
+
+	// A backup that cannot be deleted does not prevent the next backups from being created.
+	var retentionErr error
+	if schedule.Spec.Retention != nil {
+		for _, backup := range expiredBackups(schedule.Spec.Retention, backups.Items, now) {
+			if err := r.deleteExpiredBackup(ctx, &backup); err != nil {
+				log.Error(err, "Failed to delete expired backup", "CassandraBackup", backup.Name)
+				r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "DeleteExpiredBackupFailed", "Failed to delete expired CassandraBackup %s: %s", backup.Name, err)
+				retentionErr = err
+				continue
+			}
+			if schedule.Status.LastSuccessfulBackup == backup.Name {
+				schedule.Status.LastSuccessfulBackup = ""
+			}
+		}
+	}
+
+	scheduledTime, tooManyMissed := mostRecentScheduleTime(cronSchedule, lastScheduleTime(schedule), now)
+	if tooManyMissed {
+		log.Info("Too many missed schedules, scheduling a backup now", "LastScheduleTime", lastScheduleTime(schedule))
+		r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "TooManyMissedSchedules", "More than %d scheduled backups were missed since %s, scheduling a backup now", maxMissedSchedules, lastScheduleTime(schedule).Format(time.RFC3339))
+	}
+
+	if scheduledTime != nil {
+		if schedule.Spec.ConcurrencyPolicy == api.ForbidConcurrent && len(active) > 0 {
+			log.Info("Skipping scheduled backup because a previous backup is still running", "Active", schedule.Status.Active)
+		} else {
+			if schedule.Spec.ConcurrencyPolicy == api.ReplaceConcurrent {
+				for i := range active {
+					log.Info("Deleting running backup to replace it", "CassandraBackup", active[i].Name)
+					if err := r.Delete(ctx, &active[i]); client.IgnoreNotFound(err) != nil {
+						log.Error(err, "Failed to delete running backup", "CassandraBackup", active[i].Name)
+						return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+					}
+				}
+				schedule.Status.Active = nil
+			}
+
+			backup, err := r.newScheduledBackup(schedule, *scheduledTime)
+			if err != nil {
+				log.Error(err, "Failed to build CassandraBackup")
+				return ctrl.Result{}, err
+			}
+
+			log.Info("Creating scheduled backup", "CassandraBackup", backup.Name)
+			if err := r.Create(ctx, backup); err != nil && !errors.IsAlreadyExists(err) {
+				log.Error(err, "Failed to create CassandraBackup", "CassandraBackup", backup.Name)
+				return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+			}
+			schedule.Status.Active = append(schedule.Status.Active, backup.Name)
+		}
+
+		schedule.Status.LastScheduleTime = metav1.NewTime(*scheduledTime)
+	}
+
+	if err := r.Status().Patch(ctx, schedule, patch); err != nil {
+		log.Error(err, "Failed to patch CassandraBackupSchedule status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	if retentionErr != nil {
+		// The expired backups are deleted again later on.
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, retentionErr
+	}
+
+	return ctrl.Result{RequeueAfter: cronSchedule.Next(now).Sub(now)}, nil
+}
+
+// newScheduledBackup creates a CassandraBackup from the schedule's backup template. The
+// name of the object is derived from the scheduled time so that retries do not create
+// duplicates, and the name of the backup is generated from the backup name template.
+func (r *CassandraBackupScheduleReconciler) newScheduledBackup(schedule *api.CassandraBackupSchedule, scheduledTime time.Time) (*api.CassandraBackup, error) {
+	name := fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix())
+
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: schedule.Namespace,
+			Name:      name,
+			Labels: map[string]string{
+				api.BackupScheduleLabel: schedule.Name,
+			},
+		},
+		Spec: *schedule.Spec.BackupSpec.DeepCopy(),
+	}
+
+	backupName, err := api.GenerateBackupName(backup, scheduledTime)
+	if err != nil {
+		return nil, err
+	}
+	backup.Spec.Name = backupName
+	metav1.SetMetaDataAnnotation(&backup.ObjectMeta, api.GeneratedBackupNameAnnotation, "true")
+
+	if err := controllerutil.SetControllerReference(schedule, backup, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// deleteExpiredBackup purges the backup from the Medusa storage backend and then deletes the
+// CassandraBackup object. The object is kept if the purge fails so that it is retried.
+// Backups with the purge finalizer are purged by the CassandraBackup controller instead, and
+// backups that never started have nothing in storage to purge.
+func (r *CassandraBackupScheduleReconciler) deleteExpiredBackup(ctx context.Context, backup *api.CassandraBackup) error {
+	r.Log.Info("Deleting expired backup", "CassandraBackup", backup.Name, "Backup", backup.Spec.Name)
+	if !controllerutil.ContainsFinalizer(backup, backupFinalizer) && !backup.Status.StartTime.IsZero() {
+		if err := purgeBackup(ctx, r.Client, backup, r.ClientFactory, r.DefaultTLS); err != nil {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, backup))
+}
+
+// lastScheduleTime returns the time from which the next scheduled time is computed. This is
+// the creation time of the schedule when no backup has been scheduled yet.
+func lastScheduleTime(schedule *api.CassandraBackupSchedule) time.Time {
+	if !schedule.Status.LastScheduleTime.IsZero() {
+		return schedule.Status.LastScheduleTime.Time
+	}
+	return schedule.CreationTimestamp.Time
+}
+
+// maxMissedSchedules is the number of missed schedules after which mostRecentScheduleTime
+// stops walking the schedule, like the CronJob controller does.
+const maxMissedSchedules = 100
+
+// mostRecentScheduleTime returns the latest time at or before now at which a backup should
+// have been scheduled since last, or nil if no backup is due. Missed schedules are
+// collapsed into a single backup. When more than maxMissedSchedules were missed, now is
+// returned and the second return value is true.
+func mostRecentScheduleTime(schedule cron.Schedule, last, now time.Time) (*time.Time, bool) {
+	var mostRecent *time.Time
+	missed := 0
+	for t := schedule.Next(last); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		missed++
+		if missed > maxMissedSchedules {
+			return &now, true
+		}
+		scheduled := t
+		mostRecent = &scheduled
+	}
+	return mostRecent, false
+}
+
+// summarizeScheduledBackups returns the backups that have not finished yet along with the
+// most recently finished backup that succeeded.
+func summarizeScheduledBackups(backups []api.CassandraBackup) ([]api.CassandraBackup, *api.CassandraBackup) {
+	active := make([]api.CassandraBackup, 0)
+	var lastSuccessful *api.CassandraBackup
+
+	for i, backup := range backups {
+		if !backupFinished(&backup) {
+			active = append(active, backup)
+			continue
+		}
+		if !backup.IsSucceeded() {
+			continue
+		}
+		if lastSuccessful == nil || lastSuccessful.Status.FinishTime.Before(&backup.Status.FinishTime) {
+			lastSuccessful = &backups[i]
+		}
+	}
+
+	return active, lastSuccessful
+}
+
+func (r *CassandraBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.CassandraBackupSchedule{}).
+		Owns(&api.CassandraBackup{}).
+		Complete(r)
+}
@@ -0,0 +1,424 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+	"github.com/k8ssandra/medusa-operator/pkg/pb"
+)
+
+const (
+	// defaultBackupSyncInterval is how often the backups are listed when the sync does not
+	// set an interval.
+	defaultBackupSyncInterval = 10 * time.Minute
+
+	// backupImportedReason is the reason of the conditions set on imported backups.
+	backupImportedReason = "BackupImported"
+)
+
+// CassandraBackupSyncReconciler reconciles a CassandraBackupSync object
+type CassandraBackupSyncReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	medusa.ClientFactory
+	// DefaultTLS secures the connections to the sidecars of the datacenters that do not
+	// override it with annotations.
+	DefaultTLS medusa.TLSSettings
+}
+
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackupsyncs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackupsyncs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cassandra.datastax.com,namespace="medusa-operator",resources=cassandradatacenters,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=pods;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=events,verbs=create;patch
+
+func (r *CassandraBackupSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("cassandrabackupsync", req.NamespacedName)
+
+	sync := &api.CassandraBackupSync{}
+	if err := r.Get(ctx, req.NamespacedName, sync); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get CassandraBackupSync")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	summaries, err := r.listStoredBackups(ctx, sync)
+	if err != nil {
+		log.Error(err, "Failed to list the backups in storage")
+		r.Recorder.Eventf(sync, corev1.EventTypeWarning, "SyncFailed", "Failed to list the backups in storage: %s", err)
+		if err := r.setSyncCondition(ctx, sync, metav1.ConditionFalse, "SyncFailed", err.Error()); err != nil {
+			log.Error(err, "Failed to patch status")
+		}
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	backups := &api.CassandraBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(sync.Namespace)); err != nil {
+		log.Error(err, "Failed to list CassandraBackups")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	imported, err := r.importBackups(ctx, log, sync, summaries, backups.Items)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	patch := client.MergeFrom(sync.DeepCopy())
+	sync.Status.LastSyncTime = metav1.Now()
+	sync.Status.Imported = imported
+	meta.SetStatusCondition(&sync.Status.Conditions, metav1.Condition{
+		Type:               api.BackupSyncConditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackupsSynced",
+		Message:            fmt.Sprintf("%d backups found in storage", len(summaries)),
+		ObservedGeneration: sync.Generation,
+	})
+	if err := r.Status().Patch(ctx, sync, patch); err != nil {
+		log.Error(err, "Failed to patch status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	return ctrl.Result{RequeueAfter: backupSyncInterval(sync)}, nil
+}
+
+// listStoredBackups lists the backups in storage through the sidecars of the datacenter
+// of the sync.
+func (r *CassandraBackupSyncReconciler) listStoredBackups(ctx context.Context, sync *api.CassandraBackupSync) ([]*pb.BackupSummary, error) {
+	cassdc := &cassdcapi.CassandraDatacenter{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: sync.Namespace, Name: sync.Spec.CassandraDatacenter}, cassdc); err != nil {
+		return nil, err
+	}
+
+	pods, err := cassandra.GetDatacenterPods(ctx, r.Client, cassdc)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := newSidecarDialer(ctx, r.Client, cassdc, sync.Spec.Sidecar, r.ClientFactory, r.DefaultTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return getStoredBackups(ctx, pods, dialer)
+}
+
+// storedBackupKey identifies the part of a backup in storage that belongs to one
+// datacenter, which is what a CassandraBackup describes.
+type storedBackupKey struct {
+	name       string
+	datacenter string
+}
+
+// importBackups creates or updates a CassandraBackup for each datacenter of each backup in
+// storage, unless it is already described by a CassandraBackup that was not imported by
+// this sync. It returns the number of CassandraBackups imported by the sync.
+func (r *CassandraBackupSyncReconciler) importBackups(ctx context.Context, log logr.Logger, sync *api.CassandraBackupSync, summaries []*pb.BackupSummary, backups []api.CassandraBackup) (int32, error) {
+	var imported int32
+	existing := make(map[storedBackupKey]*api.CassandraBackup, len(backups))
+	for i := range backups {
+		key := storedBackupKey{backups[i].Spec.Name, backups[i].Spec.CassandraDatacenter}
+		if backups[i].Labels[api.ImportedBackupLabel] == sync.Name {
+			imported++
+			// A backup taken by the operator takes precedence over an imported one.
+			if _, found := existing[key]; found {
+				continue
+			}
+		}
+		existing[key] = &backups[i]
+	}
+
+	stored := make(map[storedBackupKey]bool)
+	for _, summary := range summaries {
+		for datacenter, nodes := range storedBackupDatacenters(summary, sync.Spec.CassandraDatacenter) {
+			if !syncsDatacenter(sync, datacenter) {
+				continue
+			}
+			key := storedBackupKey{summary.BackupName, datacenter}
+			stored[key] = true
+
+			backup, found := existing[key]
+			if !found {
+				var created bool
+				var err error
+				if backup, created, err = r.createImportedBackup(ctx, sync, key); err != nil {
+					log.Error(err, "Failed to import backup", "Backup", key.name, "Datacenter", key.datacenter)
+					return imported, err
+				}
+				if backup == nil {
+					continue
+				}
+				if created {
+					log.Info("Imported backup", "Backup", key.name, "Datacenter", key.datacenter, "CassandraBackup", backup.Name)
+					r.Recorder.Eventf(sync, corev1.EventTypeNormal, "BackupImported", "Imported backup %s of datacenter %s as CassandraBackup %s", key.name, key.datacenter, backup.Name)
+				}
+				imported++
+			} else if backup.Labels[api.ImportedBackupLabel] != sync.Name {
+				// The backup was taken by the operator or imported by another sync.
+				continue
+			}
+
+			if err := r.updateImportedBackupStatus(ctx, backup, summary, nodes); err != nil {
+				log.Error(err, "Failed to update the status of imported backup", "CassandraBackup", backup.Name)
+				return imported, err
+			}
+		}
+	}
+
+	if !sync.Spec.Prune {
+		return imported, nil
+	}
+
+	for key, backup := range existing {
+		if backup.Labels[api.ImportedBackupLabel] != sync.Name || stored[key] {
+			continue
+		}
+		log.Info("Deleting imported backup that no longer exists in storage", "CassandraBackup", backup.Name)
+		if err := r.pruneImportedBackup(ctx, backup); err != nil {
+			log.Error(err, "Failed to delete imported backup", "CassandraBackup", backup.Name)
+			return imported, err
+		}
+		r.Recorder.Eventf(sync, corev1.EventTypeNormal, "BackupPruned", "Deleted CassandraBackup %s because backup %s no longer exists in storage", backup.Name, key.name)
+		imported--
+	}
+
+	return imported, nil
+}
+
+// createImportedBackup creates the CassandraBackup describing the backup of key, unless
+// it already exists. It returns nil if another CassandraBackup already has the name of the
+// imported backup.
+func (r *CassandraBackupSyncReconciler) createImportedBackup(ctx context.Context, sync *api.CassandraBackupSync, key storedBackupKey) (*api.CassandraBackup, bool, error) {
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: sync.Namespace,
+			Name:      importedBackupName(key.name, key.datacenter),
+			Labels:    map[string]string{api.ImportedBackupLabel: sync.Name},
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                key.name,
+			CassandraDatacenter: key.datacenter,
+			// The backup in storage is shared with the CassandraBackups of the other
+			// datacenters and with the operator that took it.
+			DeletionPolicy: api.RetainBackup,
+		},
+	}
+
+	err := r.Create(ctx, backup)
+	if err == nil {
+		return backup, true, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return nil, false, err
+	}
+
+	// The backup may have been imported by a previous sync that is not in the cache yet.
+	if err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name}, backup); err != nil {
+		return nil, false, err
+	}
+	if backup.Labels[api.ImportedBackupLabel] == sync.Name && backup.Spec.Name == key.name && backup.Spec.CassandraDatacenter == key.datacenter {
+		return backup, false, nil
+	}
+	r.Recorder.Eventf(sync, corev1.EventTypeWarning, "ImportConflict", "Cannot import backup %s of datacenter %s: CassandraBackup %s already exists", key.name, key.datacenter, backup.Name)
+	return nil, false, nil
+}
+
+// updateImportedBackupStatus patches the status of an imported backup from the summary of
+// the backup in storage.
+func (r *CassandraBackupSyncReconciler) updateImportedBackupStatus(ctx context.Context, backup *api.CassandraBackup, summary *pb.BackupSummary, nodes []*pb.BackupNode) error {
+	patch := client.MergeFrom(backup.DeepCopy())
+	oldStatus := backup.Status.DeepCopy()
+
+	setImportedBackupStatus(backup, summary, nodes)
+
+	// Remote restores need the template of the datacenter, which storage does not record.
+	// It is taken from the datacenter of the same name if there is one.
+	if backup.Status.CassdcTemplateSpec == nil {
+		cassdc := &cassdcapi.CassandraDatacenter{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.CassandraDatacenter}, cassdc)
+		if err == nil {
+			backup.Status.CassdcTemplateSpec = newCassdcTemplateSpec(cassdc)
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if reflect.DeepEqual(oldStatus, &backup.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, backup, patch)
+}
+
+// pruneImportedBackup deletes an imported backup. Its finalizer is removed first as there
+// is nothing left to purge from storage.
+func (r *CassandraBackupSyncReconciler) pruneImportedBackup(ctx context.Context, backup *api.CassandraBackup) error {
+	if controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		patch := client.MergeFrom(backup.DeepCopy())
+		controllerutil.RemoveFinalizer(backup, backupFinalizer)
+		if err := r.Patch(ctx, backup, patch); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, backup))
+}
+
+func (r *CassandraBackupSyncReconciler) setSyncCondition(ctx context.Context, sync *api.CassandraBackupSync, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFrom(sync.DeepCopy())
+	meta.SetStatusCondition(&sync.Status.Conditions, metav1.Condition{
+		Type:               api.BackupSyncConditionSynced,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: sync.Generation,
+	})
+	return r.Status().Patch(ctx, sync, patch)
+}
+
+// setImportedBackupStatus sets the status of an imported backup from the summary of the
+// backup in storage. A backup is complete in storage once all of its nodes finished, in
+// which case it has a finish time. Incomplete backups are imported as running.
+func setImportedBackupStatus(backup *api.CassandraBackup, summary *pb.BackupSummary, nodes []*pb.BackupNode) {
+	if summary.StartTime > 0 {
+		backup.Status.StartTime = metav1.Unix(summary.StartTime, 0)
+	}
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionStarted,
+		Status:             metav1.ConditionTrue,
+		Reason:             backupImportedReason,
+		Message:            "The backup was imported from storage",
+		ObservedGeneration: backup.Generation,
+	})
+
+	backup.Status.Summary = newBackupSummary(summary)
+	if summary.FinishTime > 0 {
+		backup.Status.FinishTime = metav1.Unix(summary.FinishTime, 0)
+		backup.Status.Finished = make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			backup.Status.Finished = append(backup.Status.Finished, node.Host)
+		}
+		sort.Strings(backup.Status.Finished)
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:               api.BackupConditionSucceeded,
+			Status:             metav1.ConditionTrue,
+			Reason:             backupImportedReason,
+			Message:            "The backup is complete in storage",
+			ObservedGeneration: backup.Generation,
+		})
+	}
+
+	backup.Status.Phase = computeBackupPhase(backup)
+}
+
+// storedBackupDatacenters groups the nodes of a backup in storage by datacenter. Nodes
+// that do not report a datacenter, and backups without any node, are attributed to
+// defaultDatacenter.
+func storedBackupDatacenters(summary *pb.BackupSummary, defaultDatacenter string) map[string][]*pb.BackupNode {
+	datacenters := make(map[string][]*pb.BackupNode)
+	for _, node := range summary.Nodes {
+		datacenter := node.Datacenter
+		if len(datacenter) == 0 {
+			datacenter = defaultDatacenter
+		}
+		datacenters[datacenter] = append(datacenters[datacenter], node)
+	}
+	if len(datacenters) == 0 {
+		datacenters[defaultDatacenter] = nil
+	}
+	return datacenters
+}
+
+func syncsDatacenter(sync *api.CassandraBackupSync, datacenter string) bool {
+	if len(sync.Spec.Datacenters) == 0 {
+		return true
+	}
+	for _, name := range sync.Spec.Datacenters {
+		if name == datacenter {
+			return true
+		}
+	}
+	return false
+}
+
+func backupSyncInterval(sync *api.CassandraBackupSync) time.Duration {
+	if sync.Spec.Interval != nil && sync.Spec.Interval.Duration > 0 {
+		return sync.Spec.Interval.Duration
+	}
+	return defaultBackupSyncInterval
+}
+
+var invalidObjectNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// importedBackupName returns the name of the CassandraBackup describing the backup of a
+// datacenter. Backup names are not required to be valid object names, so the name is
+// sanitized. A hash of the original name is appended when it had to be changed, which
+// keeps the names of distinct backups distinct.
+func importedBackupName(backupName, datacenter string) string {
+	original := fmt.Sprintf("%s-%s", backupName, datacenter)
+	name := strings.Trim(invalidObjectNameChars.ReplaceAllString(strings.ToLower(original), "-"), "-.")
+	if name == original && len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(original))
+	if len(name) == 0 {
+		name = "backup"
+	} else if len(name) > validation.DNS1123SubdomainMaxLength-9 {
+		name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-9], "-.")
+	}
+	return name + "-" + hex.EncodeToString(hash[:])[:8]
+}
+
+func (r *CassandraBackupSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The status is patched by every sync, which must not trigger another one before the
+	// interval has elapsed.
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.CassandraBackupSync{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,461 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+)
+
+// CassandraClusterBackupReconciler reconciles a CassandraClusterBackup object
+type CassandraClusterBackupReconciler struct {
+	client.Client
+	Log           logr.Logger
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	RequeueAfter  time.Duration
+	ClientFactory medusa.ClientFactory
+	DefaultTLS    medusa.TLSSettings
+}
+
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandraclusterbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandraclusterbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cassandra.datastax.com,namespace="medusa-operator",resources=cassandradatacenters,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=pods;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=secrets,verbs=get;list;watch
+
+func (r *CassandraClusterBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("cassandraclusterbackup", req.NamespacedName)
+
+	backup := &api.CassandraClusterBackup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get CassandraClusterBackup")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	if !backup.Status.FinishTime.IsZero() {
+		log.Info("Cluster backup is already finished")
+		return ctrl.Result{}, nil
+	}
+
+	if len(backup.Spec.Name) == 0 {
+		patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		name, err := api.GenerateClusterBackupName(backup, backup.CreationTimestamp.Time)
+		if err != nil {
+			log.Error(err, "Failed to generate the backup name")
+			return ctrl.Result{}, err
+		}
+		backup.Spec.Name = name
+		metav1.SetMetaDataAnnotation(&backup.ObjectMeta, api.GeneratedBackupNameAnnotation, "true")
+		if err := r.Patch(ctx, backup, patch); err != nil {
+			log.Error(err, "Failed to set the backup name")
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+		log.Info("Generated backup name", "Backup", name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if backup.Status.StartTime.IsZero() {
+		return r.startClusterBackup(ctx, log, backup)
+	}
+
+	return r.syncClusterBackupStatus(ctx, log, backup)
+}
+
+// startClusterBackup creates a CassandraBackup for each datacenter of the cluster. All of
+// them use the backup name of the cluster backup so that they form a single backup in
+// storage.
+func (r *CassandraClusterBackupReconciler) startClusterBackup(ctx context.Context, log logr.Logger, backup *api.CassandraClusterBackup) (ctrl.Result, error) {
+	datacenters, missing, err := r.getClusterDatacenters(ctx, backup)
+	if err != nil {
+		log.Error(err, "Failed to list CassandraDatacenters")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	if len(missing) > 0 || len(datacenters) == 0 {
+		message := fmt.Sprintf("No CassandraDatacenter found for cluster %s", backup.Spec.CassandraCluster)
+		if len(missing) > 0 {
+			message = fmt.Sprintf("CassandraDatacenters %s of cluster %s not found", strings.Join(missing, ", "), backup.Spec.CassandraCluster)
+		}
+		log.Info("Cannot start the cluster backup", "Reason", message)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "DatacenterNotFound", message)
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	if stop, result, err := r.ensureUniqueBackupName(ctx, log, backup, datacenters); stop {
+		return result, err
+	}
+
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	backup.Status.Datacenters = make([]api.DatacenterBackupStatus, 0, len(datacenters))
+	for i := range datacenters {
+		dcBackup, err := r.newDatacenterBackup(backup, &datacenters[i])
+		if err != nil {
+			log.Error(err, "Failed to build CassandraBackup")
+			return ctrl.Result{}, err
+		}
+
+		log.Info("Creating datacenter backup", "CassandraBackup", dcBackup.Name)
+		if err := r.Create(ctx, dcBackup); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "Failed to create CassandraBackup", "CassandraBackup", dcBackup.Name)
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+		}
+
+		backup.Status.Datacenters = append(backup.Status.Datacenters, api.DatacenterBackupStatus{
+			Name:   datacenters[i].Name,
+			Backup: dcBackup.Name,
+			Phase:  api.BackupPhasePending,
+		})
+	}
+
+	backup.Status.StartTime = metav1.Now()
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionStarted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackupStarted",
+		Message:            fmt.Sprintf("Backup started on %d datacenters", len(datacenters)),
+		ObservedGeneration: backup.Generation,
+	})
+	backup.Status.Phase = computeClusterBackupPhase(backup)
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		log.Error(err, "Failed to patch CassandraClusterBackup status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupStarted", "Starting backup %s on %d datacenters", backup.Spec.Name, len(datacenters))
+	return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+}
+
+// ensureUniqueBackupName checks that the backup name is not used by another CassandraBackup
+// or by a backup in storage before the datacenter backups are created. A generated name is
+// made unique with a numeric suffix, while a conflicting name set by the user fails the
+// cluster backup. It returns true when the reconciliation must stop with the returned result.
+func (r *CassandraClusterBackupReconciler) ensureUniqueBackupName(ctx context.Context, log logr.Logger, backup *api.CassandraClusterBackup, datacenters []cassdcapi.CassandraDatacenter) (bool, ctrl.Result, error) {
+	backups := &api.CassandraBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(backup.Namespace)); err != nil {
+		log.Error(err, "Failed to list CassandraBackups")
+		return true, ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	existing := make(map[string]bool)
+	for _, b := range backups.Items {
+		if b.Labels[api.ClusterBackupLabel] == backup.Name {
+			// The datacenter backups have already been created by a previous reconciliation
+			// whose status update failed.
+			return false, ctrl.Result{}, nil
+		}
+		existing[b.Spec.Name] = true
+	}
+
+	stored, err := r.getStoredBackupNames(ctx, backup, datacenters)
+	if err != nil {
+		log.Error(err, "Failed to list the backups in storage")
+		r.Recorder.Eventf(backup, corev1.EventTypeWarning, "SidecarNotFound", "Cannot list the backups in storage: %s", err)
+		return true, ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+	for name := range stored {
+		existing[name] = true
+	}
+
+	if !existing[backup.Spec.Name] {
+		return false, ctrl.Result{}, nil
+	}
+
+	if metav1.HasAnnotation(backup.ObjectMeta, api.GeneratedBackupNameAnnotation) {
+		patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		backup.Spec.Name = uniqueBackupName(backup.Spec.Name, existing)
+		if err := r.Patch(ctx, backup, patch); err != nil {
+			log.Error(err, "Failed to set the backup name")
+			return true, ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+		log.Info("Generated backup name", "Backup", backup.Spec.Name)
+		return true, ctrl.Result{Requeue: true}, nil
+	}
+
+	message := fmt.Sprintf("Backup name %s is already used", backup.Spec.Name)
+	log.Info("Cannot start the cluster backup", "Reason", message)
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	backup.Status.FinishTime = metav1.Now()
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               api.BackupConditionFailed,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackupNameConflict",
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+	backup.Status.Phase = computeClusterBackupPhase(backup)
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		log.Error(err, "Failed to patch CassandraClusterBackup status")
+		return true, ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupNameConflict", message)
+	return true, ctrl.Result{}, nil
+}
+
+// syncClusterBackupStatus copies the progress of the CassandraBackup of each datacenter to
+// the status. The cluster backup finishes once all of them finished and only succeeds if
+// all of them succeeded. A datacenter backup that has been deleted is considered failed.
+// Cancelling the cluster backup cancels the datacenter backups that are still running.
+func (r *CassandraClusterBackupReconciler) syncClusterBackupStatus(ctx context.Context, log logr.Logger, backup *api.CassandraClusterBackup) (ctrl.Result, error) {
+	dcBackups := &api.CassandraBackupList{}
+	if err := r.List(ctx, dcBackups, client.InNamespace(backup.Namespace), client.MatchingLabels{api.ClusterBackupLabel: backup.Name}); err != nil {
+		log.Error(err, "Failed to list CassandraBackups")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+
+	byName := make(map[string]*api.CassandraBackup, len(dcBackups.Items))
+	for i := range dcBackups.Items {
+		byName[dcBackups.Items[i].Name] = &dcBackups.Items[i]
+	}
+
+	if backup.Spec.Cancel {
+		if err := r.cancelDatacenterBackups(ctx, log, dcBackups.Items); err != nil {
+			log.Error(err, "Failed to cancel datacenter backups")
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+	}
+
+	patch := client.MergeFromWithOptions(backup.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	finished, failed := 0, make([]string, 0)
+	for i := range backup.Status.Datacenters {
+		status := &backup.Status.Datacenters[i]
+		if dcBackup, found := byName[status.Backup]; found {
+			updateDatacenterBackupStatus(status, dcBackup)
+		} else if status.FinishTime.IsZero() && time.Since(backup.Status.StartTime.Time) > backupStartTimeout {
+			// The cache may not contain the datacenter backups right after they are
+			// created, so they are only considered deleted after a while.
+			log.Info("Datacenter backup not found", "CassandraBackup", status.Backup)
+			status.Phase = api.BackupPhaseFailed
+			status.FinishTime = metav1.Now()
+		}
+
+		// A datacenter backup that failed to start does not have a finish time.
+		if !status.FinishTime.IsZero() || status.Phase == api.BackupPhaseFailed {
+			finished++
+			if status.Phase != api.BackupPhaseSucceeded {
+				failed = append(failed, status.Name)
+			}
+		}
+	}
+
+	if finished == len(backup.Status.Datacenters) {
+		backup.Status.FinishTime = metav1.Now()
+		if len(failed) == 0 {
+			meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+				Type:               api.BackupConditionSucceeded,
+				Status:             metav1.ConditionTrue,
+				Reason:             "BackupSucceeded",
+				Message:            fmt.Sprintf("Backup finished on %d datacenters", finished),
+				ObservedGeneration: backup.Generation,
+			})
+		} else {
+			meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+				Type:               api.BackupConditionFailed,
+				Status:             metav1.ConditionTrue,
+				Reason:             "BackupFailed",
+				Message:            fmt.Sprintf("Backup failed on datacenters %s", strings.Join(failed, ", ")),
+				ObservedGeneration: backup.Generation,
+			})
+		}
+	}
+	backup.Status.Phase = computeClusterBackupPhase(backup)
+
+	if err := r.Status().Patch(ctx, backup, patch); err != nil {
+		log.Error(err, "Failed to patch CassandraClusterBackup status")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
+	if backup.Status.FinishTime.IsZero() {
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+	}
+
+	log.Info("Cluster backup finished", "Failed", failed)
+	if len(failed) == 0 {
+		r.Recorder.Eventf(backup, corev1.EventTypeNormal, "BackupSucceeded", "Backup %s finished on %d datacenters", backup.Spec.Name, finished)
+	} else {
+		r.Recorder.Eventf(backup, corev1.EventTypeWarning, "BackupFailed", "Backup %s failed on datacenters %s", backup.Spec.Name, strings.Join(failed, ", "))
+	}
+	return ctrl.Result{}, nil
+}
+
+// cancelDatacenterBackups sets the cancel field of the datacenter backups that have not
+// finished yet.
+func (r *CassandraClusterBackupReconciler) cancelDatacenterBackups(ctx context.Context, log logr.Logger, dcBackups []api.CassandraBackup) error {
+	for i := range dcBackups {
+		dcBackup := &dcBackups[i]
+		if dcBackup.Spec.Cancel || backupFinished(dcBackup) {
+			continue
+		}
+		log.Info("Cancelling datacenter backup", "CassandraBackup", dcBackup.Name)
+		patch := client.MergeFrom(dcBackup.DeepCopy())
+		dcBackup.Spec.Cancel = true
+		if err := r.Patch(ctx, dcBackup, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getClusterDatacenters returns the CassandraDatacenters of the cluster that are backed up,
+// along with the names of the requested datacenters that do not exist.
+func (r *CassandraClusterBackupReconciler) getClusterDatacenters(ctx context.Context, backup *api.CassandraClusterBackup) ([]cassdcapi.CassandraDatacenter, []string, error) {
+	dcList := &cassdcapi.CassandraDatacenterList{}
+	if err := r.List(ctx, dcList, client.InNamespace(backup.Namespace)); err != nil {
+		return nil, nil, err
+	}
+
+	datacenters := make([]cassdcapi.CassandraDatacenter, 0)
+	for _, dc := range dcList.Items {
+		if dc.Spec.ClusterName == backup.Spec.CassandraCluster {
+			datacenters = append(datacenters, dc)
+		}
+	}
+
+	if len(backup.Spec.Datacenters) == 0 {
+		return datacenters, nil, nil
+	}
+
+	selected := make([]cassdcapi.CassandraDatacenter, 0, len(backup.Spec.Datacenters))
+	missing := make([]string, 0)
+	for _, name := range backup.Spec.Datacenters {
+		found := false
+		for _, dc := range datacenters {
+			if dc.Name == name {
+				selected = append(selected, dc)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	return selected, missing, nil
+}
+
+// getStoredBackupNames returns the names of the backups in storage, as reported by the
+// sidecars of the first datacenter that responds.
+func (r *CassandraClusterBackupReconciler) getStoredBackupNames(ctx context.Context, backup *api.CassandraClusterBackup, datacenters []cassdcapi.CassandraDatacenter) (map[string]bool, error) {
+	var err error
+	for i := range datacenters {
+		var pods []corev1.Pod
+		if pods, err = cassandra.GetDatacenterPods(ctx, r.Client, &datacenters[i]); err != nil {
+			continue
+		}
+		var dialer *sidecarDialer
+		if dialer, err = newSidecarDialer(ctx, r.Client, &datacenters[i], backup.Spec.Sidecar, r.ClientFactory, r.DefaultTLS); err != nil {
+			continue
+		}
+		var names map[string]bool
+		if names, err = getStoredBackupNames(ctx, pods, dialer); err == nil {
+			return names, nil
+		}
+	}
+	return nil, err
+}
+
+// newDatacenterBackup creates the CassandraBackup of a datacenter of the cluster backup. It
+// is owned by the cluster backup.
+func (r *CassandraClusterBackupReconciler) newDatacenterBackup(backup *api.CassandraClusterBackup, dc *cassdcapi.CassandraDatacenter) (*api.CassandraBackup, error) {
+	spec := backup.Spec.DeepCopy()
+	dcBackup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: backup.Namespace,
+			Name:      fmt.Sprintf("%s-%s", backup.Name, dc.Name),
+			Labels: map[string]string{
+				api.ClusterBackupLabel: backup.Name,
+			},
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                spec.Name,
+			CassandraDatacenter: dc.Name,
+			Type:                spec.Type,
+			Sidecar:             spec.Sidecar,
+			Keyspaces:           spec.Keyspaces,
+			ExcludeKeyspaces:    spec.ExcludeKeyspaces,
+			RetryPolicy:         spec.RetryPolicy,
+			Timeout:             spec.Timeout,
+			NodeTimeout:         spec.NodeTimeout,
+			Cancel:              spec.Cancel,
+			MaxConcurrentNodes:  spec.MaxConcurrentNodes,
+			RackByRack:          spec.RackByRack,
+			Hooks:               spec.Hooks,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(backup, dcBackup, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return dcBackup, nil
+}
+
+// updateDatacenterBackupStatus copies the progress of the datacenter backup to status.
+func updateDatacenterBackupStatus(status *api.DatacenterBackupStatus, dcBackup *api.CassandraBackup) {
+	status.Phase = dcBackup.Status.Phase
+	status.StartTime = dcBackup.Status.StartTime
+	status.FinishTime = dcBackup.Status.FinishTime
+	status.Pending = dcBackup.Status.Pending
+	status.InProgress = dcBackup.Status.InProgress
+	status.Finished = dcBackup.Status.Finished
+	status.Failed = dcBackup.Status.Failed
+	status.CassdcTemplateSpec = dcBackup.Status.CassdcTemplateSpec
+}
+
+func computeClusterBackupPhase(backup *api.CassandraClusterBackup) api.BackupPhase {
+	switch {
+	case backup.IsFailed():
+		return api.BackupPhaseFailed
+	case backup.IsSucceeded():
+		return api.BackupPhaseSucceeded
+	case meta.IsStatusConditionTrue(backup.Status.Conditions, api.BackupConditionStarted):
+		return api.BackupPhaseRunning
+	default:
+		return api.BackupPhasePending
+	}
+}
+
+func (r *CassandraClusterBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.CassandraClusterBackup{}).
+		Owns(&api.CassandraBackup{}).
+		Complete(r)
+}
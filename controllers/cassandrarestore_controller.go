@@ -17,20 +17,29 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/google/uuid"
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
@@ -42,6 +51,25 @@ const (
 	restoreContainerName = "medusa-restore"
 	backupNameEnvVar     = "BACKUP_NAME"
 	restoreKeyEnvVar     = "RESTORE_KEY"
+
+	// The keyspaces and tables to restore, as comma separated lists. They are only set for
+	// partial restores.
+	keyspacesEnvVar        = "RESTORE_KEYSPACES"
+	tablesEnvVar           = "RESTORE_TABLES"
+	excludeKeyspacesEnvVar = "RESTORE_EXCLUDE_KEYSPACES"
+	excludeTablesEnvVar    = "RESTORE_EXCLUDE_TABLES"
+
+	// The time up to which the archived commit logs are replayed, in the format of the
+	// restore_point_in_time property of commitlog_archiving.properties. It is only set for
+	// point in time restores.
+	restorePointInTimeEnvVar = "RESTORE_POINT_IN_TIME"
+	restorePointInTimeFormat = "2006:01:02 15:04:05"
+
+	// The properties of commitlog_archiving.properties that replay the archived commit logs.
+	// They are managed by the operator, unlike archive_command.
+	restoreCommandProperty     = "restore_command"
+	restoreDirectoriesProperty = "restore_directories"
+	restorePointInTimeProperty = "restore_point_in_time"
 )
 
 // CassandraRestoreReconciler reconciles a CassandraRestore object
@@ -49,14 +77,21 @@ type CassandraRestoreReconciler struct {
 	client.Client
 	Log          logr.Logger
 	Scheme       *runtime.Scheme
+	Recorder     record.EventRecorder
 	RequeueAfter time.Duration
+
+	medusa.ClientFactory
+	DefaultTLS medusa.TLSSettings
 }
 
 // +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrarestores,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrarestores/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrarebackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cassandra.k8ssandra.io,namespace="medusa-operator",resources=cassandrabackups,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cassandra.datastax.com,namespace="medusa-operator",resources=cassandradatacenters,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=apps,namespace="medusa-operator",resources=statefulsets,verbs=list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=pods;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace="medusa-operator",resources=events,verbs=create;patch
 
 func (r *CassandraRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	factory := reconcile.NewFactory(r.Client, r.Log)
@@ -66,17 +101,139 @@ func (r *CassandraRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return *result, err
 	}
 
+	if !request.Restore.Status.FinishTime.IsZero() {
+		request.Log.Info("The restore operation is already complete")
+		// The replay may not have been removed if the operator stopped right after the
+		// restore finished.
+		if err := r.clearCommitLogReplay(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	errs := request.Restore.Spec.ValidateBackupSelector()
+	if len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+		request.Log.Info("The backup selector is not valid", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "InvalidBackupSelector", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "InvalidBackupSelector", message)
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		// Fixing the backup selector changes the spec, which triggers a new reconciliation.
+		return ctrl.Result{}, nil
+	}
+	resetFailedCondition(request, "InvalidBackupSelector", "BackupSelectorValid", "The backup selector is valid")
+
+	errs = request.Restore.Spec.ValidatePointInTime()
+	if len(errs) == 0 && request.Backup != nil {
+		errs = request.Restore.Spec.ValidatePointInTimeOfBackup(request.Backup)
+	}
+	if len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+		request.Log.Info("The point in time to restore is not valid", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "InvalidPointInTime", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "InvalidPointInTime", message)
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		// Fixing the point in time changes the spec, which triggers a new reconciliation.
+		return ctrl.Result{}, nil
+	}
+	resetFailedCondition(request, "InvalidPointInTime", "PointInTimeValid", "The point in time to restore is valid")
+
+	if request.Backup == nil {
+		message := fmt.Sprintf("No successful backup of CassandraDatacenter %s matches the restore", request.Restore.Spec.BackupDatacenter())
+		if deadline := request.Restore.Spec.BackupDeadline(); deadline != nil {
+			message = fmt.Sprintf("No successful backup of CassandraDatacenter %s that finished before %s matches the restore",
+				request.Restore.Spec.BackupDatacenter(), deadline.UTC().Format(time.RFC3339))
+		}
+		request.Log.Info("Cannot find the backup to restore", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "BackupNotFound", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "BackupNotFound", message)
+		// CassandraBackups are not watched, the restore proceeds once a backup that matches
+		// has succeeded.
+		return r.applyUpdatesAndRequeue(ctx, request)
+	}
+	resetFailedCondition(request, "BackupNotFound", "BackupFound", fmt.Sprintf("CassandraBackup %s matches the restore", request.Backup.Name))
+
+	errs = request.Restore.Spec.ValidateKeyspaces()
+	if len(errs) == 0 {
+		errs = request.Restore.Spec.ValidateKeyspacesInBackup(request.Backup)
+	}
+	if len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+		request.Log.Info("The keyspaces to restore are not valid", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "InvalidKeyspaces", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "InvalidKeyspaces", message)
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		// No need to requeue since the restore has not started yet. Fixing the keyspaces
+		// changes the spec, which triggers a new reconciliation.
+		return ctrl.Result{}, nil
+	}
+	resetFailedCondition(request, "InvalidKeyspaces", "KeyspacesValid", "The keyspaces to restore are valid")
+
+	if request.Restore.Status.StartTime.IsZero() && request.Restore.Spec.RestorePointInTime != nil && request.Restore.Spec.InPlace &&
+		!api.CommitLogArchivingEnabled(&request.Datacenter.Spec) {
+		message := fmt.Sprintf("Commit log archiving is not enabled in CassandraDatacenter %s, archive_command must be set in its %s config",
+			request.Datacenter.Name, api.CommitLogArchivingConfigKey)
+		request.Log.Info("Cannot replay the archived commit logs", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "CommitLogArchivingDisabled", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "CommitLogArchivingDisabled", message)
+		// The restore proceeds once commit log archiving is enabled in the datacenter.
+		return r.applyUpdatesAndRequeue(ctx, request)
+	}
+	resetFailedCondition(request, "CommitLogArchivingDisabled", "CommitLogArchivingEnabled", "Commit log archiving is enabled in the datacenter")
+
+	if request.Restore.Status.StartTime.IsZero() && !r.checkTopology(ctx, request) {
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		// The restore is reconciled again if the topology check is overridden.
+		return ctrl.Result{}, nil
+	}
+
 	request.SetRestoreStartTime(metav1.Now())
 	request.SetRestoreKey(uuid.New().String())
+	if len(request.Restore.Status.Backup) == 0 && len(request.Restore.Spec.Backup) == 0 {
+		request.Log.Info("Selected the backup to restore", "CassandraBackup", request.Backup.Name)
+		r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "BackupSelected", "Selected CassandraBackup %s", request.Backup.Name)
+	}
+	request.SetBackup(request.Backup.Name)
+	if pointInTime := request.Restore.Spec.RestorePointInTime; pointInTime != nil {
+		// The commit logs are replayed with a precision of a second.
+		request.SetReplayHorizon(metav1.NewTime(pointInTime.UTC().Truncate(time.Second)))
+		request.SetCondition(api.RestoreConditionStarted, metav1.ConditionTrue, "RestoreStarted",
+			fmt.Sprintf("Restoring backup %s and replaying the commit logs up to %s", request.Backup.Spec.Name,
+				request.Restore.Status.ReplayHorizon.UTC().Format(time.RFC3339)))
+	} else {
+		request.SetCondition(api.RestoreConditionStarted, metav1.ConditionTrue, "RestoreStarted",
+			fmt.Sprintf("Restoring backup %s", request.Backup.Spec.Name))
+	}
+
+	if !request.Restore.Spec.InPlace {
+		return r.reconcileRemoteRestore(ctx, request)
+	}
 
 	if request.Restore.Spec.Shutdown && request.Restore.Status.DatacenterStopped.IsZero() {
 		if stopped := stopDatacenter(request); !stopped {
 			return r.applyUpdatesAndRequeue(ctx, request)
 		}
+		r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "DatacenterStopped", "CassandraDatacenter %s has been stopped", request.Datacenter.Name)
+		request.OnApplied(func() {
+			recordRestorePhase(request.Restore, restorePhaseStop, request.Restore.Status.StartTime.Time, request.Restore.Status.DatacenterStopped.Time)
+		})
 	}
 
 	if err := updateRestoreInitContainer(request); err != nil {
 		request.Log.Error(err, "The datacenter is not properly configured for backup/restore")
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "RestoreContainerNotFound", err.Error())
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "RestoreContainerNotFound", err.Error())
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
 		// No need to requeue here because the datacenter is not properly configured for
 		// backup/restore with Medusa.
 		return ctrl.Result{}, err
@@ -97,9 +254,21 @@ func (r *CassandraRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	request.Log.Info("The datacenter has been updated")
 
+	if request.Restore.Status.DatacenterUpdated.IsZero() {
+		request.SetDatacenterUpdatedTime(metav1.Now())
+		rolloutStart := request.Restore.Status.StartTime
+		if !request.Restore.Status.DatacenterStopped.IsZero() {
+			rolloutStart = request.Restore.Status.DatacenterStopped
+		}
+		request.OnApplied(func() {
+			recordRestorePhase(request.Restore, restorePhaseRollout, rolloutStart.Time, request.Restore.Status.DatacenterUpdated.Time)
+		})
+	}
+
 	if request.Datacenter.Spec.Stopped {
 		request.Log.Info("Starting the datacenter")
 		request.Datacenter.Spec.Stopped = false
+		r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "StartingDatacenter", "Starting CassandraDatacenter %s", request.Datacenter.Name)
 
 		return r.applyUpdatesAndRequeue(ctx, request)
 	}
@@ -110,14 +279,250 @@ func (r *CassandraRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	}
 
 	request.SetRestoreFinishTime(metav1.Now())
+	request.SetCondition(api.RestoreConditionRestored, metav1.ConditionTrue, "RestoreSucceeded",
+		fmt.Sprintf("Backup %s has been restored", request.Backup.Spec.Name))
+	if err := r.applyUpdates(ctx, request); err != nil {
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+
+	request.Log.Info("The restore operation is complete")
+	recordRestorePhase(request.Restore, restorePhaseRestart, request.Restore.Status.DatacenterUpdated.Time, request.Restore.Status.FinishTime.Time)
+	r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "RestoreCompleted", "Backup %s has been restored", request.Backup.Spec.Name)
+	if err := r.clearCommitLogReplay(ctx, request); err != nil {
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// checkTopology records whether the topology of the backup matches the datacenter that is
+// restored. It returns false if the restore must not proceed because they do not match and
+// the mismatch has not been allowed.
+func (r *CassandraRestoreReconciler) checkTopology(ctx context.Context, request *reconcile.RestoreRequest) bool {
+	var target *cassdcapi.CassandraDatacenterSpec
+	var targetRacks map[string]int
+	var sidecarDc *cassdcapi.CassandraDatacenter
+	var pods []corev1.Pod
+	if request.Restore.Spec.InPlace {
+		target = &request.Datacenter.Spec
+		sidecarDc = request.Datacenter
+		var err error
+		if pods, err = cassandra.GetDatacenterPods(ctx, r.Client, request.Datacenter); err != nil {
+			request.Log.Info("Failed to get the pods of the datacenter", "Error", err.Error())
+		}
+		if len(pods) > 0 {
+			targetRacks = podRackSizes(pods)
+		} else {
+			// The datacenter is stopped, its nodes are placed as cass-operator does.
+			targetRacks = rackSizes(target)
+		}
+	} else if request.Backup.Status.CassdcTemplateSpec != nil {
+		// The new datacenter is created from the template of the backup.
+		target = &request.Backup.Status.CassdcTemplateSpec.Spec
+		targetRacks = rackSizes(target)
+		// The backup is listed by the sidecars of the backed up datacenter, if it still exists.
+		sidecarDc = &cassdcapi.CassandraDatacenter{}
+		dcKey := types.NamespacedName{Namespace: request.Backup.Namespace, Name: request.Backup.Spec.CassandraDatacenter}
+		if err := r.Get(ctx, dcKey, sidecarDc); err != nil {
+			sidecarDc = nil
+		} else if pods, err = cassandra.GetDatacenterPods(ctx, r.Client, sidecarDc); err != nil {
+			request.Log.Info("Failed to get the pods of the backed up datacenter", "Error", err.Error())
+		}
+	} else {
+		// The restore fails later on because the datacenter cannot be created.
+		return true
+	}
+
+	problems, known := checkRestoreTopology(request.Backup, r.getStoredSummary(ctx, request, sidecarDc, pods), target, targetRacks)
+	switch {
+	case !known:
+		request.SetCondition(api.RestoreConditionTopologyCompatible, metav1.ConditionUnknown, "TopologyUnknown",
+			fmt.Sprintf("CassandraBackup %s does not record the topology of the backup", request.Backup.Name))
+	case len(problems) == 0:
+		request.SetCondition(api.RestoreConditionTopologyCompatible, metav1.ConditionTrue, "TopologyMatches",
+			"The size, racks and number of tokens per node of the backup match the datacenter; "+
+				"the tokens owned by the nodes of the datacenter are not compared")
+	default:
+		message := strings.Join(problems, "; ")
+		request.SetCondition(api.RestoreConditionTopologyCompatible, metav1.ConditionFalse, "IncompatibleTopology", message)
+		if !request.Restore.Spec.AllowIncompatibleTopology {
+			request.Log.Info("The topology of the backup does not match the datacenter", "Reason", message)
+			message = fmt.Sprintf("The topology of the backup does not match the datacenter: %s. Set allowIncompatibleTopology to restore it anyway", message)
+			request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "IncompatibleTopology", message)
+			r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "IncompatibleTopology", message)
+			return false
+		}
+		request.Log.Info("Restoring a backup whose topology does not match the datacenter", "Reason", message)
+		r.Recorder.Eventf(request.Restore, corev1.EventTypeWarning, "IncompatibleTopologyAllowed", "Restoring a backup whose topology does not match the datacenter: %s", message)
+	}
+
+	resetFailedCondition(request, "IncompatibleTopology", "IncompatibleTopologyAllowed",
+		"The restore of a backup whose topology does not match the datacenter has been allowed")
+	return true
+}
+
+// clearCommitLogReplay removes the properties that replay the archived commit logs from the
+// config of the restored datacenter once a point in time restore is complete, and
+// cass-operator rolls the change out to the pods. Cassandra would otherwise replay the
+// archived commit logs again every time it restarts. The datacenter is left untouched if
+// another restore has updated it since.
+func (r *CassandraRestoreReconciler) clearCommitLogReplay(ctx context.Context, request *reconcile.RestoreRequest) error {
+	if request.Restore.Status.ReplayHorizon.IsZero() || request.Datacenter == nil || request.Datacenter.Spec.PodTemplateSpec == nil {
+		return nil
+	}
+	index, err := getRestoreInitContainerIndex(request.Datacenter)
+	if err != nil {
+		return nil
+	}
+	if !containerHasEnvVar(&request.Datacenter.Spec.PodTemplateSpec.Spec.InitContainers[index], restoreKeyEnvVar, request.Restore.Status.RestoreKey) {
+		return nil
+	}
+
+	// The updates of the request may already have been applied, so the datacenter is
+	// patched on its own.
+	dc := request.Datacenter
+	patch := client.MergeFromWithOptions(dc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	config := dc.Spec.Config
+	if err := setCommitLogReplayInConfig(nil, metav1.Time{}, dc); err != nil {
+		request.Log.Error(err, "Failed to remove the replay of the archived commit logs from the datacenter config")
+		return err
+	}
+	if bytes.Equal(config, dc.Spec.Config) {
+		return nil
+	}
+
+	request.Log.Info("Removing the replay of the archived commit logs from the datacenter config")
+	if err := r.Patch(ctx, dc, patch); err != nil {
+		request.Log.Error(err, "Failed to patch the CassandraDatacenter")
+		return err
+	}
+	r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "CommitLogReplayRemoved",
+		"Removed the replay of the archived commit logs from the config of CassandraDatacenter %s", request.Datacenter.Name)
+	return nil
+}
+
+// resetFailedCondition sets the Failed condition to false if it was set for failedReason.
+// This happens when the problem is solved before the restore starts, e.g. when the spec is
+// fixed, so that the phase of the restore no longer reports it as failed.
+func resetFailedCondition(request *reconcile.RestoreRequest, failedReason, reason, message string) {
+	failed := meta.FindStatusCondition(request.Restore.Status.Conditions, api.RestoreConditionFailed)
+	if failed != nil && failed.Status == metav1.ConditionTrue && failed.Reason == failedReason {
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionFalse, reason, message)
+	}
+}
+
+// getStoredSummary returns the summary of the backup as listed by the sidecars of the pods
+// of dc. The summary recorded in the status of the backup is returned when the sidecars
+// cannot be reached.
+func (r *CassandraRestoreReconciler) getStoredSummary(ctx context.Context, request *reconcile.RestoreRequest, dc *cassdcapi.CassandraDatacenter, pods []corev1.Pod) *api.BackupSummary {
+	if dc == nil || len(pods) == 0 || r.ClientFactory == nil {
+		return request.Backup.Status.Summary
+	}
+	dialer, err := newSidecarDialer(ctx, r.Client, dc, request.Backup.Spec.Sidecar, r.ClientFactory, r.DefaultTLS)
+	if err != nil {
+		request.Log.Info("Failed to configure the connection to the backup sidecars", "Error", err.Error())
+		return request.Backup.Status.Summary
+	}
+	summaries, err := getStoredBackups(ctx, pods, dialer)
+	if err != nil {
+		request.Log.Info("Failed to list the backups in storage", "Error", err.Error())
+		return request.Backup.Status.Summary
+	}
+	for _, summary := range summaries {
+		if summary.BackupName == request.Backup.Spec.Name {
+			return newBackupSummary(summary)
+		}
+	}
+	request.Log.Info("The backup is not listed in storage", "Backup", request.Backup.Spec.Name)
+	return request.Backup.Status.Summary
+}
+
+// reconcileRemoteRestore creates a new CassandraDatacenter from the backup with the restore
+// init container configured and waits for it to become ready. An existing datacenter that
+// is not owned by the CassandraRestore is never modified.
+func (r *CassandraRestoreReconciler) reconcileRemoteRestore(ctx context.Context, request *reconcile.RestoreRequest) (ctrl.Result, error) {
+	if request.Datacenter == nil {
+		// The restore key has to be persisted before the datacenter is created with it.
+		if request.RestoreModified() {
+			return r.applyUpdatesAndRequeue(ctx, request)
+		}
+		return r.createDatacenter(ctx, request)
+	}
+
+	if !metav1.IsControlledBy(request.Datacenter, request.Restore) {
+		message := fmt.Sprintf("CassandraDatacenter %s already exists", request.Datacenter.Name)
+		request.Log.Info("Cannot restore into an existing datacenter")
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "DatacenterAlreadyExists", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "DatacenterAlreadyExists", message)
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !cassandra.DatacenterReady(request.Datacenter) {
+		request.Log.Info("Waiting for the new datacenter to become ready")
+		return r.applyUpdatesAndRequeue(ctx, request)
+	}
+
+	request.SetRestoreFinishTime(metav1.Now())
+	request.SetCondition(api.RestoreConditionRestored, metav1.ConditionTrue, "RestoreSucceeded",
+		fmt.Sprintf("Backup %s has been restored into CassandraDatacenter %s", request.Backup.Spec.Name, request.Datacenter.Name))
 	if err := r.applyUpdates(ctx, request); err != nil {
 		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
 	}
 
 	request.Log.Info("The restore operation is complete")
+	recordRestorePhase(request.Restore, restorePhaseCreate, request.Restore.Status.DatacenterCreated.Time, request.Restore.Status.FinishTime.Time)
+	r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "RestoreCompleted", "Backup %s has been restored into CassandraDatacenter %s", request.Backup.Spec.Name, request.Datacenter.Name)
+	if err := r.clearCommitLogReplay(ctx, request); err != nil {
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+// createDatacenter creates the CassandraDatacenter for a remote restore.
+func (r *CassandraRestoreReconciler) createDatacenter(ctx context.Context, request *reconcile.RestoreRequest) (ctrl.Result, error) {
+	if request.Backup.Status.CassdcTemplateSpec == nil {
+		message := fmt.Sprintf("CassandraBackup %s does not have a datacenter template", request.Backup.Name)
+		request.Log.Info("Cannot create the datacenter for the restore", "Reason", message)
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "DatacenterTemplateNotFound", message)
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "DatacenterTemplateNotFound", message)
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	dc, err := buildNewCassandraDatacenter(request.Restore, request.Backup)
+	if err != nil {
+		request.Log.Error(err, "The backup datacenter is not properly configured for backup/restore")
+		request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "RestoreContainerNotFound", err.Error())
+		r.Recorder.Event(request.Restore, corev1.EventTypeWarning, "RestoreContainerNotFound", err.Error())
+		if err := r.applyUpdates(ctx, request); err != nil {
+			return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := controllerutil.SetControllerReference(request.Restore, dc, r.Scheme); err != nil {
+		request.Log.Error(err, "Failed to set owner reference on the CassandraDatacenter")
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+
+	request.Log.Info("Creating datacenter")
+	if err := r.Create(ctx, dc); err != nil {
+		request.Log.Error(err, "Failed to create the CassandraDatacenter")
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, err
+	}
+	r.Recorder.Eventf(request.Restore, corev1.EventTypeNormal, "DatacenterCreated", "Created CassandraDatacenter %s", dc.Name)
+
+	request.SetDatacenterCreatedTime(metav1.Now())
+	request.SetCondition(api.RestoreConditionDatacenterCreated, metav1.ConditionTrue, "DatacenterCreated",
+		fmt.Sprintf("CassandraDatacenter %s has been created", dc.Name))
+
+	return r.applyUpdatesAndRequeue(ctx, request)
+}
+
 // applyUpdates patches the CassandraDatacenter if its spec has been updated and patches
 // the CassandraRestore if its status has been updated.
 func (r *CassandraRestoreReconciler) applyUpdates(ctx context.Context, req *reconcile.RestoreRequest) error {
@@ -138,6 +543,8 @@ func (r *CassandraRestoreReconciler) applyUpdates(ctx context.Context, req *reco
 		}
 	}
 
+	// The metrics of the phases are only recorded once they have been persisted.
+	req.Applied()
 	return nil
 }
 
@@ -149,13 +556,23 @@ func (r *CassandraRestoreReconciler) applyUpdatesAndRequeue(ctx context.Context,
 	return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
 }
 
-// updateRestoreInitContainer sets the backup name and restore key env vars in the restore
-// init container. An error is returned if the container is not found.
+// updateRestoreInitContainer sets the backup name, restore key, keyspaces and point in time
+// env vars in the restore init container, and the replay of the archived commit logs in the
+// datacenter config. An error is returned if the container is not found.
 func updateRestoreInitContainer(req *reconcile.RestoreRequest) error {
 	if err := setBackupNameInRestoreContainer(req.Backup.Spec.Name, req.Datacenter); err != nil {
 		return err
 	}
-	return setRestoreKeyInRestoreContainer(req.Restore.Status.RestoreKey, req.Datacenter)
+	if err := setRestoreKeyInRestoreContainer(req.Restore.Status.RestoreKey, req.Datacenter); err != nil {
+		return err
+	}
+	if err := setKeyspacesInRestoreContainer(&req.Restore.Spec, req.Datacenter); err != nil {
+		return err
+	}
+	if err := setPointInTimeInRestoreContainer(req.Restore.Status.ReplayHorizon, req.Datacenter); err != nil {
+		return err
+	}
+	return setCommitLogReplayInConfig(req.Restore.Spec.CommitLogReplay, req.Restore.Status.ReplayHorizon, req.Datacenter)
 }
 
 // podTemplateSpecUpdateComplete checks that the pod template spec changes, namely the ones
@@ -180,7 +597,7 @@ func (r *CassandraRestoreReconciler) podTemplateSpecUpdateComplete(ctx context.C
 	}
 
 	for _, statefulset := range statefulsetList.Items {
-		container := getRestoreInitContainerFromStatefulSet(&statefulset)
+		container := getRestoreInitContainerFromStatefulSet(&statefulset, restoreContainerNameFor(req.Datacenter))
 
 		if container == nil {
 			return false, nil
@@ -193,6 +610,12 @@ func (r *CassandraRestoreReconciler) podTemplateSpecUpdateComplete(ctx context.C
 		if !containerHasEnvVar(container, restoreKeyEnvVar, req.Restore.Status.RestoreKey) {
 			return false, nil
 		}
+
+		for _, envVar := range append(keyspacesEnvVars(&req.Restore.Spec), pointInTimeEnvVar(req.Restore.Status.ReplayHorizon)) {
+			if getEnvVarValue(container, envVar.Name) != envVar.Value {
+				return false, nil
+			}
+		}
 	}
 
 	return true, nil
@@ -204,6 +627,8 @@ func stopDatacenter(req *reconcile.RestoreRequest) bool {
 	if cassandra.DatacenterStopped(req.Datacenter) {
 		req.Log.Info("The datacenter is stopped", "Status", req.Datacenter.Status)
 		req.SetDatacenterStoppedTime(metav1.Now())
+		req.SetCondition(api.RestoreConditionDatacenterStopped, metav1.ConditionTrue, "DatacenterStopped",
+			fmt.Sprintf("CassandraDatacenter %s has been stopped", req.Datacenter.Name))
 		return true
 	}
 
@@ -220,10 +645,16 @@ func stopDatacenter(req *reconcile.RestoreRequest) bool {
 func buildNewCassandraDatacenter(restore *api.CassandraRestore, backup *api.CassandraBackup) (*cassdcapi.CassandraDatacenter, error) {
 	newCassdc := &cassdcapi.CassandraDatacenter{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: backup.Namespace,
-			Name:      restore.Spec.CassandraDatacenter.Name,
+			Namespace:   backup.Namespace,
+			Name:        restore.Spec.CassandraDatacenter.Name,
+			Annotations: backup.Status.CassdcTemplateSpec.DeepCopy().Annotations,
 		},
-		Spec: backup.Status.CassdcTemplateSpec.Spec,
+		Spec: *backup.Status.CassdcTemplateSpec.Spec.DeepCopy(),
+	}
+	newCassdc.Spec.Stopped = false
+
+	if len(restore.Spec.CassandraDatacenter.ClusterName) > 0 {
+		newCassdc.Spec.ClusterName = restore.Spec.CassandraDatacenter.ClusterName
 	}
 
 	if err := setBackupNameInRestoreContainer(backup.Spec.Name, newCassdc); err != nil {
@@ -234,6 +665,19 @@ func buildNewCassandraDatacenter(restore *api.CassandraRestore, backup *api.Cass
 		return nil, err
 	}
 
+	if err := setKeyspacesInRestoreContainer(&restore.Spec, newCassdc); err != nil {
+		return nil, err
+	}
+
+	// The template may have been taken from a datacenter restored to a point in time.
+	if err := setPointInTimeInRestoreContainer(restore.Status.ReplayHorizon, newCassdc); err != nil {
+		return nil, err
+	}
+
+	if err := setCommitLogReplayInConfig(restore.Spec.CommitLogReplay, restore.Status.ReplayHorizon, newCassdc); err != nil {
+		return nil, err
+	}
+
 	return newCassdc, nil
 }
 
@@ -277,17 +721,136 @@ func setRestoreKeyInRestoreContainer(restoreKey string, dc *cassdcapi.CassandraD
 	return nil
 }
 
+// setKeyspacesInRestoreContainer sets the env vars with the keyspaces and tables to restore.
+// The env vars of a previous partial restore are removed when they are not needed.
+func setKeyspacesInRestoreContainer(spec *api.CassandraRestoreSpec, dc *cassdcapi.CassandraDatacenter) error {
+	return setOptionalEnvVarsInRestoreContainer(keyspacesEnvVars(spec), dc)
+}
+
+// setPointInTimeInRestoreContainer sets the env var with the time up to which the commit logs
+// are replayed. It is removed when the replay horizon is zero.
+func setPointInTimeInRestoreContainer(replayHorizon metav1.Time, dc *cassdcapi.CassandraDatacenter) error {
+	return setOptionalEnvVarsInRestoreContainer([]corev1.EnvVar{pointInTimeEnvVar(replayHorizon)}, dc)
+}
+
+// setOptionalEnvVarsInRestoreContainer sets the env vars in the restore container, removing
+// the ones with an empty value.
+func setOptionalEnvVarsInRestoreContainer(optionalEnvVars []corev1.EnvVar, dc *cassdcapi.CassandraDatacenter) error {
+	index, err := getRestoreInitContainerIndex(dc)
+	if err != nil {
+		return err
+	}
+
+	restoreContainer := &dc.Spec.PodTemplateSpec.Spec.InitContainers[index]
+	envVars := restoreContainer.Env
+	for _, envVar := range optionalEnvVars {
+		envVarIdx := getEnvVarIndex(envVar.Name, envVars)
+		if len(envVar.Value) == 0 {
+			if envVarIdx > -1 {
+				envVars = append(envVars[:envVarIdx], envVars[envVarIdx+1:]...)
+			}
+		} else if envVarIdx > -1 {
+			envVars[envVarIdx].Value = envVar.Value
+		} else {
+			envVars = append(envVars, envVar)
+		}
+	}
+	restoreContainer.Env = envVars
+
+	return nil
+}
+
+// keyspacesEnvVars returns the env vars with the keyspaces and tables to restore. The
+// values are empty when the lists are empty.
+func keyspacesEnvVars(spec *api.CassandraRestoreSpec) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: keyspacesEnvVar, Value: strings.Join(spec.Keyspaces, ",")},
+		{Name: tablesEnvVar, Value: strings.Join(spec.Tables, ",")},
+		{Name: excludeKeyspacesEnvVar, Value: strings.Join(spec.ExcludeKeyspaces, ",")},
+		{Name: excludeTablesEnvVar, Value: strings.Join(spec.ExcludeTables, ",")},
+	}
+}
+
+// pointInTimeEnvVar returns the env var with the time up to which the commit logs are
+// replayed. The value is empty when the replay horizon is zero.
+func pointInTimeEnvVar(replayHorizon metav1.Time) corev1.EnvVar {
+	envVar := corev1.EnvVar{Name: restorePointInTimeEnvVar}
+	if !replayHorizon.IsZero() {
+		envVar.Value = replayHorizon.UTC().Format(restorePointInTimeFormat)
+	}
+	return envVar
+}
+
+// setCommitLogReplayInConfig sets the properties that replay the archived commit logs up to
+// the replay horizon in the commit log archiving config of the datacenter, next to its
+// archive_command. They are removed when the replay horizon is zero so that the archived
+// commit logs are not replayed again when the datacenter restarts after another restore.
+// The config is left untouched when the properties do not change.
+func setCommitLogReplayInConfig(replay *api.CommitLogReplay, replayHorizon metav1.Time, dc *cassdcapi.CassandraDatacenter) error {
+	config := make(map[string]json.RawMessage)
+	if len(dc.Spec.Config) > 0 {
+		if err := json.Unmarshal(dc.Spec.Config, &config); err != nil {
+			return fmt.Errorf("invalid config in CassandraDatacenter %s: %s", dc.Name, err)
+		}
+	}
+
+	properties := make(map[string]interface{})
+	if raw, found := config[api.CommitLogArchivingConfigKey]; found {
+		if err := json.Unmarshal(raw, &properties); err != nil {
+			return fmt.Errorf("invalid %s config in CassandraDatacenter %s: %s", api.CommitLogArchivingConfigKey, dc.Name, err)
+		}
+	}
+
+	updated := make(map[string]interface{}, len(properties)+3)
+	for name, value := range properties {
+		updated[name] = value
+	}
+	delete(updated, restoreCommandProperty)
+	delete(updated, restoreDirectoriesProperty)
+	delete(updated, restorePointInTimeProperty)
+	if replay != nil && !replayHorizon.IsZero() {
+		updated[restoreCommandProperty] = api.DefaultCommitLogRestoreCommand
+		if len(replay.RestoreCommand) > 0 {
+			updated[restoreCommandProperty] = replay.RestoreCommand
+		}
+		updated[restoreDirectoriesProperty] = strings.Join(replay.RestoreDirectories, ",")
+		updated[restorePointInTimeProperty] = replayHorizon.UTC().Format(restorePointInTimeFormat)
+	}
+
+	if reflect.DeepEqual(properties, updated) {
+		return nil
+	}
+
+	if len(updated) == 0 {
+		delete(config, api.CommitLogArchivingConfigKey)
+	} else {
+		raw, err := json.Marshal(updated)
+		if err != nil {
+			return err
+		}
+		config[api.CommitLogArchivingConfigKey] = raw
+	}
+
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	dc.Spec.Config = raw
+	return nil
+}
+
 func getRestoreInitContainerIndex(dc *cassdcapi.CassandraDatacenter) (int, error) {
 	spec := dc.Spec.PodTemplateSpec
 	initContainers := &spec.Spec.InitContainers
+	containerName := restoreContainerNameFor(dc)
 
 	for i, container := range *initContainers {
-		if container.Name == restoreContainerName {
+		if container.Name == containerName {
 			return i, nil
 		}
 	}
 
-	return 0, fmt.Errorf("restore initContainer (%s) not found", restoreContainerName)
+	return 0, fmt.Errorf("restore initContainer (%s) not found", containerName)
 }
 
 func containerHasEnvVar(container *corev1.Container, name, value string) bool {
@@ -300,6 +863,14 @@ func containerHasEnvVar(container *corev1.Container, name, value string) bool {
 	return container.Env[idx].Value == value
 }
 
+// getEnvVarValue returns the value of the env var, or an empty string if it is not set.
+func getEnvVarValue(container *corev1.Container, name string) string {
+	if idx := getEnvVarIndex(name, container.Env); idx > -1 {
+		return container.Env[idx].Value
+	}
+	return ""
+}
+
 func getEnvVarIndex(name string, envVars []corev1.EnvVar) int {
 	for i, envVar := range envVars {
 		if envVar.Name == name {
@@ -309,9 +880,9 @@ func getEnvVarIndex(name string, envVars []corev1.EnvVar) int {
 	return -1
 }
 
-func getRestoreInitContainerFromStatefulSet(statefulset *appsv1.StatefulSet) *corev1.Container {
+func getRestoreInitContainerFromStatefulSet(statefulset *appsv1.StatefulSet, containerName string) *corev1.Container {
 	for _, container := range statefulset.Spec.Template.Spec.InitContainers {
-		if container.Name == restoreContainerName {
+		if container.Name == containerName {
 			return &container
 		}
 	}
@@ -321,5 +892,6 @@ func getRestoreInitContainerFromStatefulSet(statefulset *appsv1.StatefulSet) *co
 func (r *CassandraRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.CassandraRestore{}).
+		Owns(&cassdcapi.CassandraDatacenter{}).
 		Complete(r)
 }
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testClusterBackup(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	dc1 := &cassdcapi.CassandraDatacenter{}
+	err := testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: TestCassandraDatacenterName}, dc1)
+	require.NoError(err, "failed to get datacenter")
+
+	t.Log("creating a second datacenter in the cluster")
+	dc2 := &cassdcapi.CassandraDatacenter{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "dc2",
+		},
+		Spec: cassdcapi.CassandraDatacenterSpec{
+			ClusterName:   dc1.Spec.ClusterName,
+			ServerType:    "cassandra",
+			ServerVersion: "3.11.7",
+			Size:          2,
+			StorageConfig: cassdcapi.StorageConfig{
+				CassandraDataVolumeClaimSpec: &corev1.PersistentVolumeClaimSpec{
+					VolumeName: "data",
+				},
+			},
+		},
+	}
+	err = testClient.Create(ctx, dc2)
+	require.NoError(err, "failed to create datacenter")
+	createDatacenterService(t, ctx, dc2)
+	createDatacenterPods(t, ctx, dc2, 10)
+
+	backupName := "test-cluster-backup"
+	backupKey := types.NamespacedName{Namespace: namespace, Name: backupName}
+	backup := &api.CassandraClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupName,
+		},
+		Spec: api.CassandraClusterBackupSpec{
+			Name:             backupName,
+			CassandraCluster: dc1.Spec.ClusterName,
+			Type:             api.FullBackup,
+		},
+	}
+
+	t.Log("creating CassandraClusterBackup")
+	err = testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraClusterBackup")
+
+	t.Log("verify that the cluster backup finished")
+	require.Eventually(func() bool {
+		updated := &api.CassandraClusterBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return !updated.Status.FinishTime.IsZero()
+	}, timeout, interval)
+
+	backup = &api.CassandraClusterBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraClusterBackup")
+	require.True(meta.IsStatusConditionTrue(backup.Status.Conditions, api.BackupConditionSucceeded))
+	require.Equal(api.BackupPhaseSucceeded, backup.Status.Phase)
+	require.Len(backup.Status.Datacenters, 2)
+
+	for _, dcStatus := range backup.Status.Datacenters {
+		require.Equal(api.BackupPhaseSucceeded, dcStatus.Phase, "datacenter %s", dcStatus.Name)
+		require.NotNil(dcStatus.CassdcTemplateSpec, "datacenter %s", dcStatus.Name)
+
+		dcBackup := &api.CassandraBackup{}
+		err = testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: dcStatus.Backup}, dcBackup)
+		require.NoError(err, "failed to get CassandraBackup %s", dcStatus.Backup)
+		require.Equal(backupName, dcBackup.Spec.Name, "the backup name is shared by all datacenters")
+		require.Equal(dcStatus.Name, dcBackup.Spec.CassandraDatacenter)
+		require.Equal(api.FullBackup, dcBackup.Spec.Type)
+		require.True(metav1.IsControlledBy(dcBackup, backup), "CassandraBackup is not owned by the CassandraClusterBackup")
+	}
+
+	t.Log("verify that all the pods of the cluster are backed up")
+	requested := medusaClientFactory.GetRequestedBackups()
+	for _, index := range []int{0, 1, 2, 10, 11} {
+		assert.Contains(t, requested[fmt.Sprintf("%s:%d", getPodIpAddress(index), backupSidecarPort)], backupName)
+	}
+
+	t.Log("creating CassandraClusterBackup with a backup name that is already used")
+	conflictKey := types.NamespacedName{Namespace: namespace, Name: "test-cluster-backup-conflict"}
+	conflict := &api.CassandraClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: conflictKey.Namespace,
+			Name:      conflictKey.Name,
+		},
+		Spec: api.CassandraClusterBackupSpec{
+			Name:             backupName,
+			CassandraCluster: dc1.Spec.ClusterName,
+			Type:             api.FullBackup,
+		},
+	}
+	err = testClient.Create(ctx, conflict)
+	require.NoError(err, "failed to create CassandraClusterBackup")
+
+	t.Log("verify that the cluster backup failed without creating datacenter backups")
+	require.Eventually(func() bool {
+		updated := &api.CassandraClusterBackup{}
+		if err := testClient.Get(ctx, conflictKey, updated); err != nil {
+			return false
+		}
+		return !updated.Status.FinishTime.IsZero()
+	}, timeout, interval)
+
+	conflict = &api.CassandraClusterBackup{}
+	err = testClient.Get(ctx, conflictKey, conflict)
+	require.NoError(err, "failed to get CassandraClusterBackup")
+	condition := meta.FindStatusCondition(conflict.Status.Conditions, api.BackupConditionFailed)
+	require.NotNil(condition)
+	require.Equal("BackupNameConflict", condition.Reason)
+	require.Equal(api.BackupPhaseFailed, conflict.Status.Phase)
+	require.Empty(conflict.Status.Datacenters)
+	require.Eventually(func() bool {
+		return hasEvent(t, conflict, "BackupNameConflict")
+	}, timeout, interval)
+
+	dcBackups := &api.CassandraBackupList{}
+	err = testClient.List(ctx, dcBackups, client.InNamespace(namespace), client.MatchingLabels{api.ClusterBackupLabel: conflictKey.Name})
+	require.NoError(err, "failed to list CassandraBackups")
+	require.Empty(dcBackups.Items)
+}
+
+func TestComputeClusterBackupPhase(t *testing.T) {
+	assert := assert.New(t)
+
+	backup := &api.CassandraClusterBackup{}
+	assert.Equal(api.BackupPhasePending, computeClusterBackupPhase(backup))
+
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{Type: api.BackupConditionStarted, Status: metav1.ConditionTrue, Reason: "BackupStarted"})
+	assert.Equal(api.BackupPhaseRunning, computeClusterBackupPhase(backup))
+
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{Type: api.BackupConditionFailed, Status: metav1.ConditionTrue, Reason: "BackupFailed"})
+	assert.Equal(api.BackupPhaseFailed, computeClusterBackupPhase(backup))
+}
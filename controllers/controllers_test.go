@@ -13,6 +13,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -25,6 +26,8 @@ var cfg *rest.Config
 var testClient client.Client
 var testEnv *envtest.Environment
 var medusaClientFactory *fakeMedusaClientFactory
+var podExecutor *fakePodExecutor
+var backupScheduleClock *clock.FakeClock
 
 const (
 	TestCassandraDatacenterName = "dc1"
@@ -41,7 +44,25 @@ func TestControllers(t *testing.T) {
 	namespace := "default"
 
 	t.Run("Create Datacenter backup", controllerTest(t, ctx, namespace, testBackupDatacenter))
+	t.Run("Purge deleted backup", controllerTest(t, ctx, namespace, testBackupDeletionPolicy))
+	t.Run("Back up a subset of keyspaces", controllerTest(t, ctx, namespace, testBackupKeyspaces))
+	t.Run("Record backup errors of nodes", controllerTest(t, ctx, namespace, testBackupNodeError))
+	t.Run("Retry failed node backups", controllerTest(t, ctx, namespace, testBackupRetry))
+	t.Run("Time out node backups", controllerTest(t, ctx, namespace, testBackupTimeout))
+	t.Run("Cancel running backup", controllerTest(t, ctx, namespace, testBackupCancel))
+	t.Run("Limit concurrent node backups", controllerTest(t, ctx, namespace, testBackupConcurrencyLimit))
+	t.Run("Run backup hooks", controllerTest(t, ctx, namespace, testBackupHooks))
+	t.Run("Abort backup when a pre-backup hook fails", controllerTest(t, ctx, namespace, testFailedPreBackupHook))
+	t.Run("Generate backup names", controllerTest(t, ctx, namespace, testGeneratedBackupName))
+	t.Run("Back up all datacenters of a cluster", controllerTest(t, ctx, namespace, testClusterBackup))
+	t.Run("Import backups from storage", controllerTest(t, ctx, namespace, testBackupSync))
+	t.Run("Schedule Datacenter backups", controllerTest(t, ctx, namespace, testBackupSchedule))
+	t.Run("Delete expired scheduled backups", controllerTest(t, ctx, namespace, testBackupScheduleRetention))
+	t.Run("Fail point in time restore without backup", controllerTest(t, ctx, namespace, testPointInTimeRestoreWithoutBackup))
+	t.Run("Refuse restore with incompatible topology", controllerTest(t, ctx, namespace, testIncompatibleTopologyRestore))
 	t.Run("Restore backup in place", controllerTest(t, ctx, namespace, testInPlaceRestore))
+	t.Run("Restore backup into new datacenter", controllerTest(t, ctx, namespace, testRemoteRestore))
+	t.Run("Restore backup chosen by a selector", controllerTest(t, ctx, namespace, testSelectedBackupRestore))
 }
 
 func beforeSuite(t *testing.T) {
@@ -64,6 +85,7 @@ func beforeSuite(t *testing.T) {
 	require.NoError(err, "failed to create controller-runtime manager")
 
 	medusaClientFactory = NewMedusaClientFactory()
+	podExecutor = &fakePodExecutor{}
 
 	var log logr.Logger
 	log = logrusr.NewLogger(logrus.New())
@@ -73,18 +95,54 @@ func beforeSuite(t *testing.T) {
 		Client:        k8sManager.GetClient(),
 		Log:           log.WithName("controllers").WithName("CassandraBackup"),
 		Scheme:        scheme.Scheme,
+		Recorder:      k8sManager.GetEventRecorderFor("cassandrabackup-controller"),
 		ClientFactory: medusaClientFactory,
+		RequeueAfter:  requeueAfter,
+		PodExecutor:   podExecutor,
 	}).SetupWithManager(k8sManager)
 	require.NoError(err, "failed to set up CassandraBackupReconciler")
 
 	err = (&CassandraRestoreReconciler{
-		Client:       k8sManager.GetClient(),
-		Log:          log.WithName("controllers").WithName("CassandraRestore"),
-		Scheme:       scheme.Scheme,
-		RequeueAfter: requeueAfter,
+		Client:        k8sManager.GetClient(),
+		Log:           log.WithName("controllers").WithName("CassandraRestore"),
+		Scheme:        scheme.Scheme,
+		Recorder:      k8sManager.GetEventRecorderFor("cassandrarestore-controller"),
+		RequeueAfter:  requeueAfter,
+		ClientFactory: medusaClientFactory,
 	}).SetupWithManager(k8sManager)
 	require.NoError(err, "failed to set up CassandraRestoreReconciler")
 
+	backupScheduleClock = clock.NewFakeClock(time.Now())
+
+	err = (&CassandraBackupScheduleReconciler{
+		Client:        k8sManager.GetClient(),
+		Log:           log.WithName("controllers").WithName("CassandraBackupSchedule"),
+		Scheme:        scheme.Scheme,
+		Recorder:      k8sManager.GetEventRecorderFor("cassandrabackupschedule-controller"),
+		Clock:         backupScheduleClock,
+		ClientFactory: medusaClientFactory,
+	}).SetupWithManager(k8sManager)
+	require.NoError(err, "failed to set up CassandraBackupScheduleReconciler")
+
+	err = (&CassandraClusterBackupReconciler{
+		Client:        k8sManager.GetClient(),
+		Log:           log.WithName("controllers").WithName("CassandraClusterBackup"),
+		Scheme:        scheme.Scheme,
+		Recorder:      k8sManager.GetEventRecorderFor("cassandraclusterbackup-controller"),
+		RequeueAfter:  requeueAfter,
+		ClientFactory: medusaClientFactory,
+	}).SetupWithManager(k8sManager)
+	require.NoError(err, "failed to set up CassandraClusterBackupReconciler")
+
+	err = (&CassandraBackupSyncReconciler{
+		Client:        k8sManager.GetClient(),
+		Log:           log.WithName("controllers").WithName("CassandraBackupSync"),
+		Scheme:        scheme.Scheme,
+		Recorder:      k8sManager.GetEventRecorderFor("cassandrabackupsync-controller"),
+		ClientFactory: medusaClientFactory,
+	}).SetupWithManager(k8sManager)
+	require.NoError(err, "failed to set up CassandraBackupSyncReconciler")
+
 	go func() {
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
 		assert.NoError(t, err, "failed to start manager")
@@ -0,0 +1,337 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	defaultHookTimeout = 5 * time.Minute
+
+	// defaultHookContainer is the container in which exec hooks run by default.
+	defaultHookContainer = "cassandra"
+)
+
+// reconcileHooks runs the hooks of the stage and patches the status with their outcome. It
+// returns true once all of them are done. Exec hooks are only started once their Running
+// status has been persisted, so that a hook is never run twice.
+func (r *CassandraBackupReconciler) reconcileHooks(ctx context.Context, backup *api.CassandraBackup, stage api.HookStage, pods []corev1.Pod) (bool, error) {
+	if len(backupHooks(backup, stage)) == 0 {
+		return true, nil
+	}
+
+	original := backup.DeepCopy()
+	done, started, err := r.runHooks(ctx, backup, stage)
+	if !reflect.DeepEqual(original.Status, backup.Status) {
+		patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+		if patchErr := r.Status().Patch(ctx, backup, patch); patchErr != nil {
+			return false, patchErr
+		}
+	}
+
+	r.startHooks(backup, stage, started, pods)
+	return done, err
+}
+
+// startHooks starts the exec hooks returned by runHooks in the background, and forgets the
+// hooks whose outcome is recorded. It must only be called once the status has been persisted.
+func (r *CassandraBackupReconciler) startHooks(backup *api.CassandraBackup, stage api.HookStage, started []api.BackupHook, pods []corev1.Pod) {
+	key := client.ObjectKeyFromObject(backup)
+	for _, hook := range backupHooks(backup, stage) {
+		if status := backup.Status.GetHook(stage, hook.Name); status != nil && status.State != api.HookRunning {
+			r.hookTasks.forget(key, stage, hook.Name)
+		}
+	}
+	for i := range started {
+		hook := started[i]
+		r.hookTasks.start(key, stage, hook.Name, hookTimeout(&hook), func(ctx context.Context) error {
+			return r.runExecHook(ctx, &hook, pods)
+		})
+	}
+}
+
+// runHooks runs the hooks of the stage in order and records their outcome in the status.
+// Exec hooks run in the background and are returned to be started once their Running status
+// has been persisted, while Job hooks are created and then checked on subsequent calls. An
+// exec hook that is running but unknown to the operator, because it restarted, is failed
+// rather than run again. It returns true once all the hooks are done, or as soon as a hook
+// that fails the backup has failed.
+func (r *CassandraBackupReconciler) runHooks(ctx context.Context, backup *api.CassandraBackup, stage api.HookStage) (bool, []api.BackupHook, error) {
+	key := client.ObjectKeyFromObject(backup)
+	for _, hook := range backupHooks(backup, stage) {
+		status := backup.Status.GetHook(stage, hook.Name)
+		if status == nil {
+			r.Log.Info("Running hook", "Stage", stage, "Hook", hook.Name)
+			backup.Status.Hooks = append(backup.Status.Hooks, api.BackupHookStatus{
+				Name:      hook.Name,
+				Stage:     stage,
+				State:     api.HookRunning,
+				StartTime: metav1.Now(),
+			})
+			status = &backup.Status.Hooks[len(backup.Status.Hooks)-1]
+			if hook.Exec != nil {
+				return false, []api.BackupHook{hook}, nil
+			}
+		}
+
+		if status.State == api.HookRunning {
+			state, message := api.HookSucceeded, ""
+			if hook.Exec != nil {
+				finished, found, err := r.hookTasks.get(key, stage, hook.Name)
+				switch {
+				case !found:
+					state, message = api.HookFailed, "the operator restarted while the hook was running, it is not run again"
+				case !finished:
+					state = api.HookRunning
+				case err != nil:
+					state, message = api.HookFailed, err.Error()
+				}
+			} else {
+				var err error
+				if state, message, err = r.runJobHook(ctx, backup, stage, &hook); err != nil {
+					return false, nil, err
+				}
+			}
+			if state == api.HookRunning {
+				return false, nil, nil
+			}
+
+			status.State, status.Message, status.FinishTime = state, message, metav1.Now()
+			if state == api.HookFailed {
+				r.Log.Info("Hook failed", "Stage", stage, "Hook", hook.Name, "Reason", message)
+				r.Recorder.Eventf(backup, corev1.EventTypeWarning, "HookFailed", "%s-backup hook %s failed: %s", stage, hook.Name, message)
+			} else {
+				r.Recorder.Eventf(backup, corev1.EventTypeNormal, "HookSucceeded", "%s-backup hook %s succeeded", stage, hook.Name)
+			}
+		}
+
+		if status.State == api.HookFailed && hook.FailurePolicy != api.IgnoreHookFailure {
+			return true, nil, nil
+		}
+	}
+	return true, nil, nil
+}
+
+// runExecHook runs the command of the hook in every pod that has its container. The
+// command runs in all the pods even if it fails in some of them.
+func (r *CassandraBackupReconciler) runExecHook(ctx context.Context, hook *api.BackupHook, pods []corev1.Pod) error {
+	container := hook.Exec.Container
+	if len(container) == 0 {
+		container = defaultHookContainer
+	}
+
+	errs := make([]error, 0)
+	for i := range pods {
+		if !cassandra.HasContainer(&pods[i], container) {
+			errs = append(errs, fmt.Errorf("container %s not found in pod %s", container, pods[i].Name))
+			continue
+		}
+		if _, err := r.PodExecutor.Exec(ctx, &pods[i], container, hook.Exec.Command); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// hookTasks keeps track of the exec hooks that run in the background, and of their outcome
+// until it has been recorded in the status. The zero value is ready to use.
+type hookTasks struct {
+	mutex sync.Mutex
+	tasks map[hookTaskKey]*hookTask
+}
+
+type hookTaskKey struct {
+	backup types.NamespacedName
+	stage  api.HookStage
+	hook   string
+}
+
+type hookTask struct {
+	cancel   context.CancelFunc
+	finished bool
+	err      error
+}
+
+// start runs the hook in the background with the given timeout.
+func (t *hookTasks) start(key types.NamespacedName, stage api.HookStage, hook string, timeout time.Duration, run func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	task := &hookTask{cancel: cancel}
+
+	t.mutex.Lock()
+	if t.tasks == nil {
+		t.tasks = make(map[hookTaskKey]*hookTask)
+	}
+	t.tasks[hookTaskKey{key, stage, hook}] = task
+	t.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+		err := run(ctx)
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		task.finished, task.err = true, err
+	}()
+}
+
+// get returns whether the hook finished and its error. found is false if the hook is not
+// known, either because it never started or because the operator restarted since.
+func (t *hookTasks) get(key types.NamespacedName, stage api.HookStage, hook string) (finished, found bool, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	task := t.tasks[hookTaskKey{key, stage, hook}]
+	if task == nil {
+		return false, false, nil
+	}
+	return task.finished, true, task.err
+}
+
+// forget removes a finished hook whose outcome has been recorded.
+func (t *hookTasks) forget(key types.NamespacedName, stage api.HookStage, hook string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if task := t.tasks[hookTaskKey{key, stage, hook}]; task != nil && task.finished {
+		delete(t.tasks, hookTaskKey{key, stage, hook})
+	}
+}
+
+// cancelAll aborts the hooks of the backup that are still running and forgets all of them.
+func (t *hookTasks) cancelAll(key types.NamespacedName) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for taskKey, task := range t.tasks {
+		if taskKey.backup == key {
+			task.cancel()
+			delete(t.tasks, taskKey)
+		}
+	}
+}
+
+// runJobHook creates the Job of the hook if needed and returns its state. The Job is owned
+// by the backup so that it is deleted with it.
+func (r *CassandraBackupReconciler) runJobHook(ctx context.Context, backup *api.CassandraBackup, stage api.HookStage, hook *api.BackupHook) (api.HookState, string, error) {
+	key := types.NamespacedName{Namespace: backup.Namespace, Name: hookJobName(backup.Name, stage, hook.Name)}
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, key, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", "", err
+		}
+
+		job = newHookJob(backup, key, hook)
+		if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+			return "", "", err
+		}
+		r.Log.Info("Creating hook Job", "Job", key)
+		if err := r.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+			return api.HookFailed, fmt.Sprintf("failed to create Job %s: %s", key.Name, err), nil
+		}
+		return api.HookRunning, "", nil
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return api.HookSucceeded, "", nil
+		case batchv1.JobFailed:
+			return api.HookFailed, fmt.Sprintf("Job %s failed: %s", key.Name, condition.Message), nil
+		}
+	}
+	return api.HookRunning, "", nil
+}
+
+func newHookJob(backup *api.CassandraBackup, key types.NamespacedName, hook *api.BackupHook) *batchv1.Job {
+	template := hook.Job.Template.DeepCopy()
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[api.BackupHookLabel] = backup.Name
+	if job.Spec.ActiveDeadlineSeconds == nil {
+		deadline := int64(hookTimeout(hook).Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+	return job
+}
+
+// hookJobName returns the name of the Job of a hook. Names that would be too long for a Job
+// are truncated and made unique with a hash.
+func hookJobName(backupName string, stage api.HookStage, hookName string) string {
+	name := fmt.Sprintf("%s-%s-%s", backupName, strings.ToLower(string(stage)), hookName)
+	if len(name) <= 63 {
+		return name
+	}
+	hash := sha256.Sum256([]byte(name))
+	return strings.TrimRight(name[:54], "-.") + "-" + hex.EncodeToString(hash[:])[:8]
+}
+
+func hookTimeout(hook *api.BackupHook) time.Duration {
+	if hook.Timeout != nil && hook.Timeout.Duration > 0 {
+		return hook.Timeout.Duration
+	}
+	return defaultHookTimeout
+}
+
+func backupHooks(backup *api.CassandraBackup, stage api.HookStage) []api.BackupHook {
+	if backup.Spec.Hooks == nil {
+		return nil
+	}
+	if stage == api.PreBackupHook {
+		return backup.Spec.Hooks.Pre
+	}
+	return backup.Spec.Hooks.Post
+}
+
+// failedHook returns the status of the hook of the stage whose failure fails the backup,
+// or nil if there is none.
+func failedHook(backup *api.CassandraBackup, stage api.HookStage) *api.BackupHookStatus {
+	for _, hook := range backupHooks(backup, stage) {
+		status := backup.Status.GetHook(stage, hook.Name)
+		if status != nil && status.State == api.HookFailed && hook.FailurePolicy != api.IgnoreHookFailure {
+			return status
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testBackupHooks(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-hooks"}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                backupKey.Name,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			Hooks: &api.BackupHooks{
+				Pre: []api.BackupHook{
+					{Name: "flush", Exec: &api.ExecHook{Command: []string{"nodetool", "flush"}}},
+				},
+				Post: []api.BackupHook{
+					{Name: "index", Job: &api.JobHook{Template: batchv1.JobTemplateSpec{
+						Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							Containers:    []corev1.Container{{Name: "index", Image: "index"}},
+						}}},
+					}}},
+					{Name: "notify", Exec: &api.ExecHook{Command: []string{"false"}}, FailurePolicy: api.IgnoreHookFailure},
+				},
+			},
+		},
+	}
+
+	t.Log("creating CassandraBackup with hooks")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the post-backup Job is created once the backup is done")
+	jobKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-hooks-post-index"}
+	job := &batchv1.Job{}
+	require.Eventually(func() bool {
+		return testClient.Get(ctx, jobKey, job) == nil
+	}, timeout, interval)
+	require.Equal(backupKey.Name, job.Labels[api.BackupHookLabel])
+	require.NotNil(job.Spec.ActiveDeadlineSeconds)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Len(backup.Status.Finished, 3)
+	require.True(backup.Status.FinishTime.IsZero(), "the backup finishes after the post-backup hooks")
+
+	t.Log("completing the post-backup Job")
+	patch := client.MergeFrom(job.DeepCopy())
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobComplete,
+		Status: corev1.ConditionTrue,
+	})
+	err = testClient.Status().Patch(ctx, job, patch)
+	require.NoError(err, "failed to patch Job status")
+
+	t.Log("verify that the backup succeeded")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsSucceeded()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Equal(api.HookSucceeded, backup.Status.GetHook(api.PreBackupHook, "flush").State)
+	require.Equal(api.HookSucceeded, backup.Status.GetHook(api.PostBackupHook, "index").State)
+	require.Equal(api.HookFailed, backup.Status.GetHook(api.PostBackupHook, "notify").State, "ignored failures are recorded")
+
+	t.Log("verify that the exec hooks ran in all the pods")
+	require.Len(backup.Status.Nodes, 3)
+	for _, node := range backup.Status.Nodes {
+		commands := podExecutor.getCommands(namespace, node.Pod)
+		require.Contains(commands, "cassandra: nodetool flush", "pod %s", node.Pod)
+		require.Contains(commands, "cassandra: false", "pod %s", node.Pod)
+	}
+}
+
+func testFailedPreBackupHook(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	backupKey := types.NamespacedName{Namespace: namespace, Name: "test-backup-hook-failure"}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      backupKey.Name,
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                backupKey.Name,
+			CassandraDatacenter: TestCassandraDatacenterName,
+			Hooks: &api.BackupHooks{
+				Pre: []api.BackupHook{
+					{Name: "pause", Exec: &api.ExecHook{Command: []string{"false"}}},
+				},
+				Post: []api.BackupHook{
+					{Name: "resume", Exec: &api.ExecHook{Command: []string{"true"}}},
+				},
+			},
+		},
+	}
+
+	t.Log("creating CassandraBackup with a failing pre-backup hook")
+	err := testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	t.Log("verify that the backup failed")
+	require.Eventually(func() bool {
+		updated := &api.CassandraBackup{}
+		if err := testClient.Get(ctx, backupKey, updated); err != nil {
+			return false
+		}
+		return updated.IsFailed()
+	}, timeout, interval)
+
+	backup = &api.CassandraBackup{}
+	err = testClient.Get(ctx, backupKey, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	require.Equal("PreBackupHookFailed", meta.FindStatusCondition(backup.Status.Conditions, api.BackupConditionFailed).Reason)
+	require.True(backup.Status.StartTime.IsZero(), "the backup is not started")
+	require.Equal(api.HookSucceeded, backup.Status.GetHook(api.PostBackupHook, "resume").State, "the post-backup hooks still run")
+
+	for _, requested := range medusaClientFactory.GetRequestedBackups() {
+		require.NotContains(requested, backupKey.Name)
+	}
+}
+
+// fakePodExecutor records the commands run in each pod. The "false" command fails.
+type fakePodExecutor struct {
+	mutex    sync.Mutex
+	commands map[string][]string
+}
+
+func (e *fakePodExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.commands == nil {
+		e.commands = make(map[string][]string)
+	}
+	key := pod.Namespace + "/" + pod.Name
+	e.commands[key] = append(e.commands[key], container+": "+strings.Join(command, " "))
+	if command[0] == "false" {
+		return "", fmt.Errorf("command false in pod %s: exit code 1", pod.Name)
+	}
+	return "", nil
+}
+
+func (e *fakePodExecutor) getCommands(namespace, pod string) []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]string{}, e.commands[namespace+"/"+pod]...)
+}
+
+func TestHookJobName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("backup-pre-flush", hookJobName("backup", api.PreBackupHook, "flush"))
+
+	long := hookJobName(strings.Repeat("b", 60), api.PostBackupHook, "notify")
+	assert.Len(long, 63)
+	assert.NotEqual(long, hookJobName(strings.Repeat("b", 60), api.PostBackupHook, "notify2"))
+}
+
+func TestFailedHook(t *testing.T) {
+	assert := assert.New(t)
+
+	backup := &api.CassandraBackup{
+		Spec: api.CassandraBackupSpec{Hooks: &api.BackupHooks{Post: []api.BackupHook{
+			{Name: "ignored", FailurePolicy: api.IgnoreHookFailure},
+			{Name: "required"},
+		}}},
+		Status: api.CassandraBackupStatus{Hooks: []api.BackupHookStatus{
+			{Name: "ignored", Stage: api.PostBackupHook, State: api.HookFailed},
+			{Name: "required", Stage: api.PostBackupHook, State: api.HookSucceeded},
+		}},
+	}
+	assert.Nil(failedHook(backup, api.PostBackupHook))
+	assert.Nil(failedHook(backup, api.PreBackupHook))
+
+	backup.Status.Hooks[1].State = api.HookFailed
+	assert.Equal("required", failedHook(backup, api.PostBackupHook).Name)
+}
+
+func TestRunExecHooks(t *testing.T) {
+	require := require.New(t)
+
+	r := &CassandraBackupReconciler{Log: logr.Discard(), Recorder: record.NewFakeRecorder(10)}
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec: api.CassandraBackupSpec{Hooks: &api.BackupHooks{Pre: []api.BackupHook{
+			{Name: "flush", Exec: &api.ExecHook{Command: []string{"nodetool", "flush"}}},
+		}}},
+	}
+
+	done, started, err := r.runHooks(context.Background(), backup, api.PreBackupHook)
+	require.NoError(err)
+	require.False(done)
+	require.Len(started, 1, "the hook is started once its status is persisted")
+	require.Equal(api.HookRunning, backup.Status.GetHook(api.PreBackupHook, "flush").State)
+
+	finished := make(chan struct{})
+	r.hookTasks.start(client.ObjectKeyFromObject(backup), api.PreBackupHook, "flush", time.Minute, func(ctx context.Context) error {
+		<-finished
+		return nil
+	})
+	done, started, err = r.runHooks(context.Background(), backup, api.PreBackupHook)
+	require.NoError(err)
+	require.False(done, "the hook is still running")
+	require.Empty(started)
+
+	close(finished)
+	require.Eventually(func() bool {
+		done, _, _ = r.runHooks(context.Background(), backup, api.PreBackupHook)
+		return done
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(api.HookSucceeded, backup.Status.GetHook(api.PreBackupHook, "flush").State)
+
+	t.Log("verify that a running hook is not run again after a restart")
+	restarted := &CassandraBackupReconciler{Log: logr.Discard(), Recorder: record.NewFakeRecorder(10)}
+	backup.Status.GetHook(api.PreBackupHook, "flush").State = api.HookRunning
+	done, started, err = restarted.runHooks(context.Background(), backup, api.PreBackupHook)
+	require.NoError(err)
+	require.True(done)
+	require.Empty(started)
+	require.Equal(api.HookFailed, backup.Status.GetHook(api.PreBackupHook, "flush").State)
+}
@@ -0,0 +1,170 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+)
+
+const (
+	metricsNamespace = "medusa_operator"
+
+	restorePhaseStop    = "stop"
+	restorePhaseRollout = "rollout"
+	restorePhaseRestart = "restart"
+	restorePhaseCreate  = "create"
+)
+
+var (
+	backupsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "backups_started_total",
+		Help:      "Number of backups started",
+	}, []string{"namespace", "datacenter", "type"})
+
+	backupsSucceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "backups_succeeded_total",
+		Help:      "Number of backups that finished on all pods",
+	}, []string{"namespace", "datacenter", "type"})
+
+	backupsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "backups_failed_total",
+		Help:      "Number of backups that failed on at least one pod",
+	}, []string{"namespace", "datacenter", "type"})
+
+	podBackupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "pod_backup_duration_seconds",
+		Help:      "Time it took to back up a single pod, as observed by the operator",
+		Buckets:   prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{"namespace", "datacenter", "type"})
+
+	restorePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "restore_phase_duration_seconds",
+		Help:      "Time spent in each phase of a restore: stop, rollout, restart, or create for remote restores",
+		Buckets:   prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{"namespace", "datacenter", "phase"})
+
+	lastSuccessfulBackup = newLastBackupCollector()
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		backupsStarted,
+		backupsSucceeded,
+		backupsFailed,
+		podBackupDuration,
+		restorePhaseDuration,
+		lastSuccessfulBackup,
+	)
+}
+
+// recordBackupStarted updates the metrics of a backup that has just been started.
+func recordBackupStarted(backup *api.CassandraBackup) {
+	backupsStarted.WithLabelValues(backup.Namespace, backup.Spec.CassandraDatacenter, string(backup.Spec.Type)).Inc()
+}
+
+// recordPodBackupFinished observes the duration of the last attempt to back up a single pod,
+// as recorded in the status of its node.
+func recordPodBackupFinished(backup *api.CassandraBackup, podName string) {
+	node := backup.Status.GetNode(podName)
+	if node == nil {
+		return
+	}
+	podBackupDuration.WithLabelValues(backup.Namespace, backup.Spec.CassandraDatacenter, string(backup.Spec.Type)).
+		Observe(node.FinishTime.Sub(nodeStartTime(backup, podName).Time).Seconds())
+}
+
+// recordBackupFinished updates the metrics of a backup that has just finished, including the
+// backups that failed before they started.
+func recordBackupFinished(backup *api.CassandraBackup) {
+	labels := []string{backup.Namespace, backup.Spec.CassandraDatacenter, string(backup.Spec.Type)}
+	if backup.IsFailed() {
+		backupsFailed.WithLabelValues(labels...).Inc()
+	} else {
+		backupsSucceeded.WithLabelValues(labels...).Inc()
+		lastSuccessfulBackup.set(backup.Namespace, backup.Spec.CassandraDatacenter, backup.Status.FinishTime.Time)
+	}
+}
+
+// recordRestorePhase observes the duration of a restore phase that ran from start to end.
+func recordRestorePhase(restore *api.CassandraRestore, phase string, start, end time.Time) {
+	restorePhaseDuration.WithLabelValues(restore.Namespace, restore.Spec.CassandraDatacenter.Name, phase).
+		Observe(end.Sub(start).Seconds())
+}
+
+type datacenterKey struct {
+	namespace  string
+	datacenter string
+}
+
+// lastBackupCollector reports the time elapsed since the last successful backup of each
+// datacenter. The elapsed time is computed when the metric is collected so that it keeps
+// growing when no backups are taken.
+type lastBackupCollector struct {
+	desc  *prometheus.Desc
+	mutex sync.Mutex
+	times map[datacenterKey]time.Time
+}
+
+func newLastBackupCollector() *lastBackupCollector {
+	return &lastBackupCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "seconds_since_last_successful_backup"),
+			"Time elapsed since the last successful backup of the datacenter finished",
+			[]string{"namespace", "datacenter"},
+			nil,
+		),
+		times: make(map[datacenterKey]time.Time),
+	}
+}
+
+// set records t as the finish time of a successful backup unless a more recent one is
+// already known. Finished backups are reconciled when the operator starts, so this also
+// restores the metric after a restart.
+func (c *lastBackupCollector) set(namespace, datacenter string, t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := datacenterKey{namespace: namespace, datacenter: datacenter}
+	if last, found := c.times[key]; !found || t.After(last) {
+		c.times[key] = t
+	}
+}
+
+func (c *lastBackupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lastBackupCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for key, t := range c.times {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(t).Seconds(), key.namespace, key.datacenter)
+	}
+}
@@ -2,16 +2,25 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
 	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/pb"
+	"github.com/k8ssandra/medusa-operator/pkg/reconcile"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func testInPlaceRestore(t *testing.T, ctx context.Context, namespace string) {
@@ -23,9 +32,11 @@ func testInPlaceRestore(t *testing.T, ctx context.Context, namespace string) {
 			Name:      "test-restore",
 		},
 		Spec: api.CassandraRestoreSpec{
-			Backup:   "test-backup",
-			Shutdown: true,
-			InPlace:  true,
+			Backup:           "test-backup",
+			Shutdown:         true,
+			InPlace:          true,
+			Keyspaces:        []string{"ks1"},
+			ExcludeKeyspaces: []string{"ks1"},
 			CassandraDatacenter: api.CassandraDatacenterConfig{
 				Name:        TestCassandraDatacenterName,
 				ClusterName: "test-dc",
@@ -38,6 +49,23 @@ func testInPlaceRestore(t *testing.T, ctx context.Context, namespace string) {
 	err := testClient.Create(ctx, restore)
 	require.NoError(err, "failed to create CassandraRestore")
 
+	t.Log("verify that the restore fails since the keyspace is both included and excluded")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		failed := meta.FindStatusCondition(restore.Status.Conditions, api.RestoreConditionFailed)
+		return failed != nil && failed.Status == metav1.ConditionTrue && failed.Reason == "InvalidKeyspaces"
+	}, timeout, interval)
+
+	t.Log("fix the keyspaces to restore")
+	patch := client.MergeFrom(restore.DeepCopy())
+	restore.Spec.Keyspaces = nil
+	restore.Spec.ExcludeKeyspaces = nil
+	err = testClient.Patch(ctx, restore, patch)
+	require.NoError(err, "failed to patch CassandraRestore")
+
 	dcKey := types.NamespacedName{Namespace: "default", Name: TestCassandraDatacenterName}
 
 	withDc := newWithDatacenter(t, ctx, dcKey)
@@ -135,9 +163,334 @@ func testInPlaceRestore(t *testing.T, ctx context.Context, namespace string) {
 
 		return !restore.Status.FinishTime.IsZero()
 	}, timeout, interval)
+
+	t.Log("check restore conditions")
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionStarted))
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionDatacenterStopped))
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionRestored))
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionTopologyCompatible))
+	require.Equal(api.RestorePhaseSucceeded, restore.Status.Phase)
+	require.Equal("test-backup", restore.Status.Backup)
+	require.True(meta.IsStatusConditionFalse(restore.Status.Conditions, api.RestoreConditionFailed), "the fixed keyspaces are no longer reported")
+	require.True(restore.Status.ReplayHorizon.IsZero())
+
+	t.Log("verify that events are recorded for the restore")
+	require.Eventually(func() bool {
+		return hasEvent(t, restore, "DatacenterStopped") && hasEvent(t, restore, "RestoreCompleted")
+	}, timeout, interval)
+}
+
+func testPointInTimeRestoreWithoutBackup(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	pointInTime := metav1.NewTime(time.Now().AddDate(-1, 0, 0))
+	restore := &api.CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-restore-point-in-time",
+		},
+		Spec: api.CassandraRestoreSpec{
+			InPlace:            true,
+			RestorePointInTime: &pointInTime,
+			CommitLogReplay:    &api.CommitLogReplay{RestoreDirectories: []string{"/var/lib/cassandra/archive"}},
+			CassandraDatacenter: api.CassandraDatacenterConfig{
+				Name:        TestCassandraDatacenterName,
+				ClusterName: "test-dc",
+			},
+		},
+	}
+	restoreKey := types.NamespacedName{Namespace: restore.Namespace, Name: restore.Name}
+
+	err := testClient.Create(ctx, restore)
+	require.NoError(err, "failed to create CassandraRestore")
+
+	t.Log("verify that the restore fails since no backup finished before the point in time")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		return restore.Status.Phase == api.RestorePhaseFailed
+	}, timeout, interval)
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	failed := meta.FindStatusCondition(restore.Status.Conditions, api.RestoreConditionFailed)
+	require.NotNil(failed)
+	require.Equal("BackupNotFound", failed.Reason)
+	require.True(restore.Status.StartTime.IsZero())
+	require.Empty(restore.Status.Backup)
+
+	dc := &cassdcapi.CassandraDatacenter{}
+	err = testClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: TestCassandraDatacenterName}, dc)
+	require.NoError(err, "failed to get CassandraDatacenter")
+	require.False(dc.Spec.Stopped, "the datacenter is not stopped")
+}
+
+func testRemoteRestore(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	restore := &api.CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-remote-restore",
+		},
+		Spec: api.CassandraRestoreSpec{
+			Backup:  "test-backup",
+			InPlace: false,
+			CassandraDatacenter: api.CassandraDatacenterConfig{
+				Name:        "restored-dc",
+				ClusterName: "restored-cluster",
+			},
+			Keyspaces:     []string{"ks1", "ks2"},
+			ExcludeTables: []string{"ks1.table1"},
+		},
+	}
+	restoreKey := types.NamespacedName{Namespace: restore.Namespace, Name: restore.Name}
+
+	err := testClient.Create(ctx, restore)
+	require.NoError(err, "failed to create CassandraRestore")
+
+	dcKey := types.NamespacedName{Namespace: namespace, Name: restore.Spec.CassandraDatacenter.Name}
+	withDc := newWithDatacenter(t, ctx, dcKey)
+
+	t.Log("check that the datacenter is created from the backup")
+	require.Eventually(withDc(func(dc *cassdcapi.CassandraDatacenter) bool {
+		return true
+	}), timeout, interval, "timed out waiting for CassandraDatacenter to be created")
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+
+	dc := &cassdcapi.CassandraDatacenter{}
+	err = testClient.Get(ctx, dcKey, dc)
+	require.NoError(err, "failed to get CassandraDatacenter")
+	require.Equal("restored-cluster", dc.Spec.ClusterName)
+	require.True(metav1.IsControlledBy(dc, restore), "CassandraDatacenter is not owned by the CassandraRestore")
+
+	restoreContainer := findContainer(dc.Spec.PodTemplateSpec.Spec.InitContainers, "medusa-restore")
+	require.NotNil(restoreContainer, "restore init container not found")
+	envVar := findEnvVar(restoreContainer.Env, "BACKUP_NAME")
+	require.NotNil(envVar)
+	require.Equal("test-backup", envVar.Value)
+	envVar = findEnvVar(restoreContainer.Env, "RESTORE_KEY")
+	require.NotNil(envVar)
+	require.Equal(restore.Status.RestoreKey, envVar.Value)
+	envVar = findEnvVar(restoreContainer.Env, "RESTORE_KEYSPACES")
+	require.NotNil(envVar)
+	require.Equal("ks1,ks2", envVar.Value)
+	envVar = findEnvVar(restoreContainer.Env, "RESTORE_EXCLUDE_TABLES")
+	require.NotNil(envVar)
+	require.Equal("ks1.table1", envVar.Value)
+	require.Nil(findEnvVar(restoreContainer.Env, "RESTORE_TABLES"))
+
+	t.Log("set datacenter status to ready")
+	err = patchDatacenterStatus(ctx, dcKey, func(dc *cassdcapi.CassandraDatacenter) {
+		dc.Status.CassandraOperatorProgress = cassdcapi.ProgressReady
+		dc.SetCondition(cassdcapi.DatacenterCondition{
+			Type:               cassdcapi.DatacenterReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+		})
+	})
+	require.NoError(err, "failed to update datacenter status with ready condition")
+
+	t.Log("check restore status finish time set")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		return !restore.Status.FinishTime.IsZero()
+	}, timeout, interval)
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionDatacenterCreated))
+	require.True(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionRestored))
+	require.Equal(api.RestorePhaseSucceeded, restore.Status.Phase)
+}
+
+func testIncompatibleTopologyRestore(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	t.Log("store a backup of dc1 that only has two nodes")
+	medusaClientFactory.setStoredBackups(&pb.BackupSummary{
+		BackupName:    "topology-backup",
+		TotalNodes:    2,
+		FinishedNodes: 2,
+		Nodes: []*pb.BackupNode{
+			{Host: "10.0.0.1", Datacenter: TestCassandraDatacenterName, Rack: "rack1", Tokens: []int64{0}},
+			{Host: "10.0.0.2", Datacenter: TestCassandraDatacenterName, Rack: "rack1", Tokens: []int64{1000}},
+		},
+	})
+	defer medusaClientFactory.setStoredBackups()
+
+	dc := &cassdcapi.CassandraDatacenter{}
+	err := testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: TestCassandraDatacenterName}, dc)
+	require.NoError(err, "failed to get CassandraDatacenter")
+
+	// The backup is labelled as imported so that the backup controller leaves it alone.
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-topology-backup",
+			Labels:    map[string]string{api.ImportedBackupLabel: "test-topology"},
+		},
+		Spec: api.CassandraBackupSpec{
+			Name:                "topology-backup",
+			CassandraDatacenter: TestCassandraDatacenterName,
+		},
+	}
+	err = testClient.Create(ctx, backup)
+	require.NoError(err, "failed to create CassandraBackup")
+
+	patch := client.MergeFrom(backup.DeepCopy())
+	backup.Status.CassdcTemplateSpec = newCassdcTemplateSpec(dc)
+	backup.Status.FinishTime = metav1.Now()
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{Type: api.BackupConditionSucceeded, Status: metav1.ConditionTrue, Reason: "BackupImported"})
+	err = testClient.Status().Patch(ctx, backup, patch)
+	require.NoError(err, "failed to patch CassandraBackup status")
+
+	restore := &api.CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-topology-restore",
+		},
+		Spec: api.CassandraRestoreSpec{
+			Backup: backup.Name,
+			CassandraDatacenter: api.CassandraDatacenterConfig{
+				Name:        "topology-dc",
+				ClusterName: "topology-cluster",
+			},
+		},
+	}
+	restoreKey := types.NamespacedName{Namespace: restore.Namespace, Name: restore.Name}
+
+	err = testClient.Create(ctx, restore)
+	require.NoError(err, "failed to create CassandraRestore")
+
+	t.Log("verify that the restore is refused since the backup has fewer nodes than its template")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		return restore.Status.Phase == api.RestorePhaseFailed
+	}, timeout, interval)
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	failed := meta.FindStatusCondition(restore.Status.Conditions, api.RestoreConditionFailed)
+	require.NotNil(failed)
+	require.Equal("IncompatibleTopology", failed.Reason)
+	require.Contains(failed.Message, "the backup has 2 nodes but the datacenter has 3")
+	require.True(meta.IsStatusConditionFalse(restore.Status.Conditions, api.RestoreConditionTopologyCompatible))
+	require.True(restore.Status.StartTime.IsZero())
+
+	dcKey := types.NamespacedName{Namespace: namespace, Name: restore.Spec.CassandraDatacenter.Name}
+	require.True(errors.IsNotFound(testClient.Get(ctx, dcKey, &cassdcapi.CassandraDatacenter{})), "the datacenter is not created")
+
+	t.Log("allow the incompatible topology")
+	patch = client.MergeFrom(restore.DeepCopy())
+	restore.Spec.AllowIncompatibleTopology = true
+	err = testClient.Patch(ctx, restore, patch)
+	require.NoError(err, "failed to patch CassandraRestore")
+
+	t.Log("verify that the datacenter is created")
+	withDc := newWithDatacenter(t, ctx, dcKey)
+	require.Eventually(withDc(func(dc *cassdcapi.CassandraDatacenter) bool {
+		return true
+	}), timeout, interval, "timed out waiting for CassandraDatacenter to be created")
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	require.False(meta.IsStatusConditionTrue(restore.Status.Conditions, api.RestoreConditionFailed))
+	require.True(meta.IsStatusConditionFalse(restore.Status.Conditions, api.RestoreConditionTopologyCompatible))
+	require.False(restore.Status.StartTime.IsZero())
+
+	require.Eventually(func() bool {
+		return hasEvent(t, restore, "IncompatibleTopologyAllowed")
+	}, timeout, interval)
 }
 
 // newWithDatacenter is a function generator for withDatacenter that is bound to t, ctx, and key.
+func testSelectedBackupRestore(t *testing.T, ctx context.Context, namespace string) {
+	require := require.New(t)
+
+	restore := &api.CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "test-selected-restore",
+		},
+		Spec: api.CassandraRestoreSpec{
+			BackupSelector: &api.BackupSelector{
+				Datacenter:    TestCassandraDatacenterName,
+				Latest:        true,
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"restore-test": "selected"}},
+			},
+			CassandraDatacenter: api.CassandraDatacenterConfig{
+				Name:        "selected-dc",
+				ClusterName: "selected-cluster",
+			},
+		},
+	}
+	restoreKey := types.NamespacedName{Namespace: restore.Namespace, Name: restore.Name}
+
+	err := testClient.Create(ctx, restore)
+	require.NoError(err, "failed to create CassandraRestore")
+
+	t.Log("verify that the restore fails while no backup matches the selector")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		failed := meta.FindStatusCondition(restore.Status.Conditions, api.RestoreConditionFailed)
+		return failed != nil && failed.Status == metav1.ConditionTrue && failed.Reason == "BackupNotFound"
+	}, timeout, interval)
+
+	t.Log("label the backup to select")
+	backup := &api.CassandraBackup{}
+	err = testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "test-backup"}, backup)
+	require.NoError(err, "failed to get CassandraBackup")
+	patch := client.MergeFrom(backup.DeepCopy())
+	backup.Labels = map[string]string{"restore-test": "selected"}
+	err = testClient.Patch(ctx, backup, patch)
+	require.NoError(err, "failed to patch CassandraBackup")
+
+	t.Log("check that the selected backup is recorded in the status")
+	require.Eventually(func() bool {
+		restore := &api.CassandraRestore{}
+		if err := testClient.Get(ctx, restoreKey, restore); err != nil {
+			return false
+		}
+		return len(restore.Status.Backup) > 0
+	}, timeout, interval)
+
+	restore = &api.CassandraRestore{}
+	err = testClient.Get(ctx, restoreKey, restore)
+	require.NoError(err, "failed to get CassandraRestore")
+	require.Equal("test-backup", restore.Status.Backup)
+	require.True(meta.IsStatusConditionFalse(restore.Status.Conditions, api.RestoreConditionFailed), "the restore is no longer failed")
+	require.NotEqual(api.RestorePhaseFailed, restore.Status.Phase)
+
+	t.Log("check that the datacenter is created from the selected backup")
+	dcKey := types.NamespacedName{Namespace: namespace, Name: restore.Spec.CassandraDatacenter.Name}
+	require.Eventually(newWithDatacenter(t, ctx, dcKey)(func(dc *cassdcapi.CassandraDatacenter) bool {
+		restoreContainer := findContainer(dc.Spec.PodTemplateSpec.Spec.InitContainers, "medusa-restore")
+		return restoreContainer != nil && getEnvVarValue(restoreContainer, "BACKUP_NAME") == backup.Spec.Name
+	}), timeout, interval, "timed out waiting for CassandraDatacenter to be created")
+}
+
 func newWithDatacenter(t *testing.T, ctx context.Context, key types.NamespacedName) func(func(*cassdcapi.CassandraDatacenter) bool) func() bool {
 	return func(condition func(dc *cassdcapi.CassandraDatacenter) bool) func() bool {
 		return withDatacenter(t, ctx, key, condition)
@@ -190,3 +543,214 @@ func patchDatacenterStatus(ctx context.Context, key types.NamespacedName, update
 }
 
 //func updateStatefulSet
+
+func TestSetKeyspacesInRestoreContainer(t *testing.T) {
+	require := require.New(t)
+
+	dc := &cassdcapi.CassandraDatacenter{
+		Spec: cassdcapi.CassandraDatacenterSpec{
+			PodTemplateSpec: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{
+							Name: "medusa-restore",
+							Env: []corev1.EnvVar{
+								{Name: "MEDUSA_MODE", Value: "RESTORE"},
+								{Name: "RESTORE_KEYSPACES", Value: "ks1"},
+								{Name: "RESTORE_TABLES", Value: "ks2.table1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := setKeyspacesInRestoreContainer(&api.CassandraRestoreSpec{Keyspaces: []string{"ks3", "ks4"}}, dc)
+	require.NoError(err)
+	env := dc.Spec.PodTemplateSpec.Spec.InitContainers[0].Env
+	require.Equal([]corev1.EnvVar{
+		{Name: "MEDUSA_MODE", Value: "RESTORE"},
+		{Name: "RESTORE_KEYSPACES", Value: "ks3,ks4"},
+	}, env)
+
+	err = setKeyspacesInRestoreContainer(&api.CassandraRestoreSpec{}, dc)
+	require.NoError(err)
+	env = dc.Spec.PodTemplateSpec.Spec.InitContainers[0].Env
+	require.Equal([]corev1.EnvVar{{Name: "MEDUSA_MODE", Value: "RESTORE"}}, env)
+
+	err = setKeyspacesInRestoreContainer(&api.CassandraRestoreSpec{}, &cassdcapi.CassandraDatacenter{
+		Spec: cassdcapi.CassandraDatacenterSpec{PodTemplateSpec: &corev1.PodTemplateSpec{}},
+	})
+	require.Error(err, "the restore container is required")
+}
+
+func TestSetPointInTimeInRestoreContainer(t *testing.T) {
+	require := require.New(t)
+
+	dc := &cassdcapi.CassandraDatacenter{
+		Spec: cassdcapi.CassandraDatacenterSpec{
+			PodTemplateSpec: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{
+							Name: "medusa-restore",
+							Env:  []corev1.EnvVar{{Name: "MEDUSA_MODE", Value: "RESTORE"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	replayHorizon := metav1.NewTime(time.Date(2021, time.November, 15, 2, 30, 0, 0, time.FixedZone("CET", 3600)))
+	err := setPointInTimeInRestoreContainer(replayHorizon, dc)
+	require.NoError(err)
+	env := dc.Spec.PodTemplateSpec.Spec.InitContainers[0].Env
+	require.Equal([]corev1.EnvVar{
+		{Name: "MEDUSA_MODE", Value: "RESTORE"},
+		{Name: "RESTORE_POINT_IN_TIME", Value: "2021:11:15 01:30:00"},
+	}, env, "the point in time is in UTC")
+
+	err = setPointInTimeInRestoreContainer(metav1.Time{}, dc)
+	require.NoError(err)
+	env = dc.Spec.PodTemplateSpec.Spec.InitContainers[0].Env
+	require.Equal([]corev1.EnvVar{{Name: "MEDUSA_MODE", Value: "RESTORE"}}, env)
+}
+
+func TestSetCommitLogReplayInConfig(t *testing.T) {
+	require := require.New(t)
+
+	dc := &cassdcapi.CassandraDatacenter{
+		Spec: cassdcapi.CassandraDatacenterSpec{
+			Config: json.RawMessage(`{"cassandra-yaml":{"num_tokens":16},"commitlog-archiving-properties":{"archive_command":"/bin/ln %path /archive/%name"}}`),
+		},
+	}
+	original := dc.Spec.Config
+
+	err := setCommitLogReplayInConfig(nil, metav1.Time{}, dc)
+	require.NoError(err)
+	require.Equal(original, dc.Spec.Config, "the config is not rewritten when nothing changes")
+
+	replay := &api.CommitLogReplay{RestoreDirectories: []string{"/archive", "/archive2"}}
+	replayHorizon := metav1.NewTime(time.Date(2021, time.November, 15, 2, 30, 0, 0, time.FixedZone("CET", 3600)))
+	err = setCommitLogReplayInConfig(replay, replayHorizon, dc)
+	require.NoError(err)
+	require.JSONEq(`{
+		"cassandra-yaml": {"num_tokens": 16},
+		"commitlog-archiving-properties": {
+			"archive_command": "/bin/ln %path /archive/%name",
+			"restore_command": "cp -f %from %to",
+			"restore_directories": "/archive,/archive2",
+			"restore_point_in_time": "2021:11:15 01:30:00"
+		}
+	}`, string(dc.Spec.Config))
+
+	replay.RestoreCommand = "cp %from %to"
+	err = setCommitLogReplayInConfig(replay, replayHorizon, dc)
+	require.NoError(err)
+	require.Contains(string(dc.Spec.Config), `"restore_command":"cp %from %to"`)
+
+	err = setCommitLogReplayInConfig(replay, metav1.Time{}, dc)
+	require.NoError(err)
+	require.JSONEq(string(original), string(dc.Spec.Config), "the commit logs are not replayed without a replay horizon")
+
+	dc.Spec.Config = nil
+	err = setCommitLogReplayInConfig(nil, metav1.Time{}, dc)
+	require.NoError(err)
+	require.Nil(dc.Spec.Config)
+}
+
+func TestResetFailedCondition(t *testing.T) {
+	require := require.New(t)
+
+	request := &reconcile.RestoreRequest{Restore: &api.CassandraRestore{}}
+	request.SetCondition(api.RestoreConditionStarted, metav1.ConditionTrue, "RestoreStarted", "Restoring backup")
+	request.SetCondition(api.RestoreConditionFailed, metav1.ConditionTrue, "BackupNotFound", "No backup matches the restore")
+	require.Equal(api.RestorePhaseFailed, request.Restore.Status.Phase)
+
+	resetFailedCondition(request, "InvalidBackupSelector", "BackupSelectorValid", "The backup selector is valid")
+	require.True(meta.IsStatusConditionTrue(request.Restore.Status.Conditions, api.RestoreConditionFailed), "only the given reason is reset")
+
+	resetFailedCondition(request, "BackupNotFound", "BackupFound", "CassandraBackup test-backup matches the restore")
+	require.True(meta.IsStatusConditionFalse(request.Restore.Status.Conditions, api.RestoreConditionFailed))
+	require.Equal(api.RestorePhaseRunning, request.Restore.Status.Phase)
+}
+
+func TestPointInTimeRestoreClearsCommitLogReplay(t *testing.T) {
+	require := require.New(t)
+	require.NoError(registerApis())
+
+	ctx := context.Background()
+	archivingConfig := json.RawMessage(`{"commitlog-archiving-properties":{"archive_command":"/bin/ln %path /archive/%name"}}`)
+	pointInTime := metav1.NewTime(time.Now().Truncate(time.Second))
+	replay := &api.CommitLogReplay{RestoreDirectories: []string{"/archive"}}
+
+	backup := &api.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+		Spec:       api.CassandraBackupSpec{Name: "backup", CassandraDatacenter: "dc1"},
+		Status: api.CassandraBackupStatus{
+			StartTime:  metav1.NewTime(pointInTime.Add(-2 * time.Hour)),
+			FinishTime: metav1.NewTime(pointInTime.Add(-time.Hour)),
+			Conditions: []metav1.Condition{{Type: api.BackupConditionSucceeded, Status: metav1.ConditionTrue}},
+			CassdcTemplateSpec: &api.CassandraDatacenterTemplateSpec{
+				Spec: cassdcapi.CassandraDatacenterSpec{Config: archivingConfig},
+			},
+		},
+	}
+	// The restore has been rolled out and the datacenter is back online after replaying the
+	// archived commit logs.
+	restore := &api.CassandraRestore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "restore"},
+		Spec: api.CassandraRestoreSpec{
+			Backup:              "backup",
+			InPlace:             true,
+			RestorePointInTime:  &pointInTime,
+			CommitLogReplay:     replay,
+			CassandraDatacenter: api.CassandraDatacenterConfig{Name: "dc1", ClusterName: "test"},
+		},
+		Status: api.CassandraRestoreStatus{
+			StartTime:         metav1.NewTime(pointInTime.Add(-time.Minute)),
+			DatacenterUpdated: metav1.NewTime(pointInTime.Add(-time.Minute)),
+			RestoreKey:        "restore-key",
+			Backup:            "backup",
+			ReplayHorizon:     pointInTime,
+		},
+	}
+	dc := &cassdcapi.CassandraDatacenter{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dc1"},
+		Spec: cassdcapi.CassandraDatacenterSpec{
+			ClusterName: "test",
+			Size:        1,
+			Config:      archivingConfig,
+			PodTemplateSpec: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "medusa-restore"}},
+				},
+			},
+		},
+		Status: cassdcapi.CassandraDatacenterStatus{
+			CassandraOperatorProgress: cassdcapi.ProgressReady,
+			Conditions: []cassdcapi.DatacenterCondition{
+				{Type: cassdcapi.DatacenterUpdating, Status: corev1.ConditionFalse, LastTransitionTime: metav1.NewTime(pointInTime.Add(-time.Minute))},
+				{Type: cassdcapi.DatacenterReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	request := &reconcile.RestoreRequest{Restore: restore, Backup: backup, Datacenter: dc}
+	require.NoError(updateRestoreInitContainer(request))
+	require.Contains(string(dc.Spec.Config), "restore_point_in_time")
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(backup, restore, dc).Build()
+	r := &CassandraRestoreReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10), RequeueAfter: time.Second}
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: restore.Name}})
+	require.NoError(err)
+
+	updated := &api.CassandraRestore{}
+	require.NoError(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: restore.Name}, updated))
+	require.False(updated.Status.FinishTime.IsZero(), "the restore is complete")
+
+	require.NoError(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: dc.Name}, dc))
+	require.JSONEq(string(archivingConfig), string(dc.Spec.Config), "the archived commit logs are not replayed again")
+}
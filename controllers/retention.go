@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+)
+
+// expiredBackups returns the finished backups that are not retained by the policy. Backups
+// that are still running are never returned.
+func expiredBackups(policy *api.RetentionPolicy, backups []api.CassandraBackup, now time.Time) []api.CassandraBackup {
+	finished := make([]api.CassandraBackup, 0, len(backups))
+	for _, backup := range backups {
+		if backupFinished(&backup) {
+			finished = append(finished, backup)
+		}
+	}
+
+	// Most recent first. Status times only have second precision, so ties are broken by
+	// name which includes the scheduled time for scheduled backups.
+	sort.SliceStable(finished, func(i, j int) bool {
+		ti, tj := backupTime(&finished[i]), backupTime(&finished[j])
+		if ti.Equal(tj) {
+			return finished[i].Name > finished[j].Name
+		}
+		return ti.After(tj)
+	})
+
+	successful := make([]api.CassandraBackup, 0, len(finished))
+	for _, backup := range finished {
+		if backup.IsSucceeded() {
+			successful = append(successful, backup)
+		}
+	}
+
+	retained := make(map[string]bool)
+	if policy.KeepLast == 0 && policy.KeepDaily == 0 && policy.KeepWeekly == 0 && policy.KeepMonthly == 0 {
+		for _, backup := range successful {
+			retained[backup.Name] = true
+		}
+	} else {
+		for i := 0; i < len(successful) && i < int(policy.KeepLast); i++ {
+			retained[successful[i].Name] = true
+		}
+		retainPerPeriod(successful, policy.KeepDaily, retained, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+		retainPerPeriod(successful, policy.KeepWeekly, retained, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		})
+		retainPerPeriod(successful, policy.KeepMonthly, retained, func(t time.Time) string {
+			return t.Format("2006-01")
+		})
+	}
+
+	expired := make([]api.CassandraBackup, 0)
+	for _, backup := range finished {
+		t := backupTime(&backup)
+		if policy.MaxAge != nil && now.Sub(t) > policy.MaxAge.Duration {
+			expired = append(expired, backup)
+			continue
+		}
+		if !backup.IsSucceeded() {
+			// Failed backups are kept until a more recent backup succeeded.
+			if len(successful) > 0 && backupTime(&successful[0]).After(t) {
+				expired = append(expired, backup)
+			}
+			continue
+		}
+		if !retained[backup.Name] {
+			expired = append(expired, backup)
+		}
+	}
+
+	return expired
+}
+
+// retainPerPeriod marks the most recent backup of each of the count most recent periods as
+// retained. backups must be sorted from most to least recent.
+func retainPerPeriod(backups []api.CassandraBackup, count int32, retained map[string]bool, period func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, backup := range backups {
+		if len(seen) >= int(count) {
+			return
+		}
+		key := period(backupTime(&backup).UTC())
+		if !seen[key] {
+			seen[key] = true
+			retained[backup.Name] = true
+		}
+	}
+}
+
+// backupTime returns the time at which the backup was started, falling back to the
+// creation time of the object for backups that never started.
+func backupTime(backup *api.CassandraBackup) time.Time {
+	if !backup.Status.StartTime.IsZero() {
+		return backup.Status.StartTime.Time
+	}
+	return backup.CreationTimestamp.Time
+}
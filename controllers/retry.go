@@ -0,0 +1,91 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 30 * time.Second
+	defaultMaxBackoff     = 10 * time.Minute
+)
+
+// retryPolicy is an api.RetryPolicy with the defaults applied. Without a policy, the backup
+// of a node is attempted only once.
+type retryPolicy struct {
+	maxAttempts    int32
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newRetryPolicy(policy *api.RetryPolicy) retryPolicy {
+	if policy == nil {
+		return retryPolicy{maxAttempts: 1}
+	}
+	p := retryPolicy{
+		maxAttempts:    policy.MaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = defaultMaxAttempts
+	}
+	if policy.InitialBackoff != nil {
+		p.initialBackoff = policy.InitialBackoff.Duration
+	}
+	if policy.MaxBackoff != nil {
+		p.maxBackoff = policy.MaxBackoff.Duration
+	}
+	return p
+}
+
+// backoff returns how long to wait after the given number of failed attempts before
+// trying again.
+func (p retryPolicy) backoff(attempts int32) time.Duration {
+	backoff := p.initialBackoff
+	for i := int32(1); i < attempts && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return backoff
+}
+
+// hasRetriableNodes returns true if the backup failed on a node that has attempts left.
+func (p retryPolicy) hasRetriableNodes(backup *api.CassandraBackup) bool {
+	for _, podName := range backup.Status.Failed {
+		if node := backup.Status.GetNode(podName); node != nil && node.Attempts < p.maxAttempts {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sortPodsByRack orders the pods by the position of their rack in the racks of the
+// CassandraDatacenter, then by name. The pods of unknown racks come last.
+func sortPodsByRack(pods []corev1.Pod, racks []cassdcapi.Rack) {
+	position := make(map[string]int, len(racks))
+	for i, rack := range racks {
+		position[rack.Name] = i
+	}
+	rackPosition := func(pod *corev1.Pod) int {
+		if i, found := position[pod.Labels[cassdcapi.RackLabel]]; found {
+			return i
+		}
+		return len(racks)
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		pi, pj := rackPosition(&pods[i]), rackPosition(&pods[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return pods[i].Name < pods[j].Name
+	})
+}
+
+// startPendingNodes moves the pending pods that can be backed up now to the in progress
+// list and returns their names. The busy pods are the ones that are in progress or waiting
+// to be retried; they count towards maxConcurrentNodes and, when racks are backed up one
+// after the other, hold back the pods of the other racks.
+func startPendingNodes(backup *api.CassandraBackup, busy []string, now time.Time) []string {
+	limit := int(backup.Spec.MaxConcurrentNodes)
+	running := len(busy)
+
+	var rack string
+	rackSelected := false
+	if backup.Spec.RackByRack {
+		for _, podName := range busy {
+			if node := backup.Status.GetNode(podName); node != nil {
+				rack, rackSelected = node.Rack, true
+				break
+			}
+		}
+	}
+
+	started := make([]string, 0)
+	pending := backup.Status.Pending
+	backup.Status.Pending = make([]string, 0, len(pending))
+	for _, podName := range pending {
+		node := backup.Status.GetNode(podName)
+		if node == nil || (limit > 0 && running >= limit) {
+			backup.Status.Pending = append(backup.Status.Pending, podName)
+			continue
+		}
+		if backup.Spec.RackByRack {
+			if !rackSelected {
+				rack, rackSelected = node.Rack, true
+			}
+			if node.Rack != rack {
+				backup.Status.Pending = append(backup.Status.Pending, podName)
+				continue
+			}
+		}
+
+		node.State = api.NodeBackupInProgress
+		node.StartTime = metav1.NewTime(now)
+		node.Attempts = 1
+		backup.Status.InProgress = append(backup.Status.InProgress, podName)
+		started = append(started, podName)
+		running++
+	}
+	return started
+}
@@ -0,0 +1,164 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+)
+
+// sidecarDialer connects to the Medusa sidecars of a datacenter.
+type sidecarDialer struct {
+	clientFactory medusa.ClientFactory
+	tlsConfig     *tls.Config
+	containerName string
+
+	// port is discovered from the sidecar container when it is zero.
+	port int32
+}
+
+// newSidecarDialer returns a dialer for the sidecars of cassdc. The sidecar container and
+// port are taken from override, then from the annotations of cassdc. The TLS Secret is
+// read every time a dialer is created, so rotated certificates are used by new
+// connections without restarting the operator.
+func newSidecarDialer(ctx context.Context, c client.Client, cassdc *cassdcapi.CassandraDatacenter, override *api.SidecarConfig, clientFactory medusa.ClientFactory, defaultTLS medusa.TLSSettings) (*sidecarDialer, error) {
+	dialer := &sidecarDialer{clientFactory: clientFactory, containerName: backupSidecarName}
+
+	if name, found := cassdc.Annotations[medusa.SidecarContainerAnnotation]; found {
+		dialer.containerName = name
+	}
+	if value, found := cassdc.Annotations[medusa.SidecarPortAnnotation]; found {
+		port, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for annotation %s: %s", medusa.SidecarPortAnnotation, err)
+		}
+		dialer.port = int32(port)
+	}
+	if override != nil {
+		if len(override.ContainerName) > 0 {
+			dialer.containerName = override.ContainerName
+		}
+		if override.Port > 0 {
+			dialer.port = override.Port
+		}
+	}
+
+	settings := defaultTLS.WithAnnotations(cassdc.Annotations)
+	if !settings.Enabled() {
+		return dialer, nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{Namespace: cassdc.Namespace, Name: settings.SecretName}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		return nil, fmt.Errorf("failed to get TLS secret %s: %s", secretKey, err)
+	}
+
+	tlsConfig, err := medusa.NewTLSConfig(secret, settings.ServerName)
+	if err != nil {
+		return nil, err
+	}
+	dialer.tlsConfig = tlsConfig
+
+	return dialer, nil
+}
+
+// dial creates a client for the Medusa sidecar of the pod.
+func (d *sidecarDialer) dial(pod *corev1.Pod) (medusa.Client, error) {
+	return d.clientFactory.NewClient(d.address(pod), d.tlsConfig)
+}
+
+// address returns the address of the gRPC server of the sidecar. Unless the port has been
+// configured, the container port named grpc is used, falling back to the default port.
+func (d *sidecarDialer) address(pod *corev1.Pod) string {
+	port := d.port
+	if port == 0 {
+		port = backupSidecarPort
+		if container := d.findSidecar(pod); container != nil {
+			for _, containerPort := range container.Ports {
+				if containerPort.Name == medusa.SidecarPortName {
+					port = containerPort.ContainerPort
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%s:%d", pod.Status.PodIP, port)
+}
+
+func (d *sidecarDialer) findSidecar(pod *corev1.Pod) *corev1.Container {
+	for i, container := range pod.Spec.Containers {
+		if container.Name == d.containerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+// hasSidecar returns true if the pod runs the sidecar container.
+func (d *sidecarDialer) hasSidecar(pod *corev1.Pod) bool {
+	return d.findSidecar(pod) != nil
+}
+
+// podsWithoutSidecar returns the names of the pods that do not run the sidecar container.
+func (d *sidecarDialer) podsWithoutSidecar(pods []corev1.Pod) []string {
+	missing := make([]string, 0)
+	for i := range pods {
+		if !d.hasSidecar(&pods[i]) {
+			missing = append(missing, pods[i].Name)
+		}
+	}
+	return missing
+}
+
+// restoreContainerNameFor returns the name of the restore init container of the datacenter.
+func restoreContainerNameFor(cassdc *cassdcapi.CassandraDatacenter) string {
+	if name, found := cassdc.Annotations[medusa.RestoreContainerAnnotation]; found {
+		return name
+	}
+	return restoreContainerName
+}
+
+// medusaAnnotations returns the annotations of cassdc that configure how the Medusa
+// containers are located and connected to.
+func medusaAnnotations(cassdc *cassdcapi.CassandraDatacenter) map[string]string {
+	var annotations map[string]string
+	for _, key := range []string{
+		medusa.SidecarContainerAnnotation,
+		medusa.SidecarPortAnnotation,
+		medusa.RestoreContainerAnnotation,
+		medusa.TLSSecretAnnotation,
+		medusa.TLSServerNameAnnotation,
+	} {
+		if value, found := cassdc.Annotations[key]; found {
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
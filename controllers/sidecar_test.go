@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/k8ssandra/medusa-operator/pkg/medusa"
+)
+
+func TestNewSidecarDialer(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t)
+
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "medusa-ca"},
+		Data:       map[string][]byte{medusa.TLSCAKey: certPEM},
+	}
+	mtlsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "medusa-mtls"},
+		Data: map[string][]byte{
+			medusa.TLSCAKey:         certPEM,
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(caSecret, mtlsSecret).Build()
+	ctx := context.Background()
+
+	newDatacenter := func(annotations map[string]string) *cassdcapi.CassandraDatacenter {
+		return &cassdcapi.CassandraDatacenter{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dc1", Annotations: annotations},
+		}
+	}
+
+	t.Run("plaintext by default", func(t *testing.T) {
+		dialer, err := newSidecarDialer(ctx, c, newDatacenter(nil), nil, nil, medusa.TLSSettings{})
+		require.NoError(t, err)
+		assert.Nil(t, dialer.tlsConfig)
+	})
+
+	t.Run("global TLS settings", func(t *testing.T) {
+		dialer, err := newSidecarDialer(ctx, c, newDatacenter(nil), nil, nil, medusa.TLSSettings{SecretName: "medusa-ca", ServerName: "medusa"})
+		require.NoError(t, err)
+		require.NotNil(t, dialer.tlsConfig)
+		assert.Equal(t, "medusa", dialer.tlsConfig.ServerName)
+		assert.Empty(t, dialer.tlsConfig.Certificates)
+	})
+
+	t.Run("datacenter annotations override global settings", func(t *testing.T) {
+		dc := newDatacenter(map[string]string{
+			medusa.TLSSecretAnnotation:     "medusa-mtls",
+			medusa.TLSServerNameAnnotation: "dc1-medusa",
+		})
+		dialer, err := newSidecarDialer(ctx, c, dc, nil, nil, medusa.TLSSettings{SecretName: "medusa-ca", ServerName: "medusa"})
+		require.NoError(t, err)
+		require.NotNil(t, dialer.tlsConfig)
+		assert.Equal(t, "dc1-medusa", dialer.tlsConfig.ServerName)
+		assert.Len(t, dialer.tlsConfig.Certificates, 1)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, err := newSidecarDialer(ctx, c, newDatacenter(nil), nil, nil, medusa.TLSSettings{SecretName: "missing"})
+		assert.Error(t, err)
+	})
+}
+
+func TestSidecarDialerDiscovery(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	ctx := context.Background()
+
+	newPod := func(name, containerName string, ports ...corev1.ContainerPort) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "cassandra"},
+					{Name: containerName, Ports: ports},
+				},
+			},
+			Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+		}
+	}
+	grpcPort := corev1.ContainerPort{Name: medusa.SidecarPortName, ContainerPort: 6000}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		override    *api.SidecarConfig
+		pod         corev1.Pod
+		hasSidecar  bool
+		address     string
+	}{
+		{
+			name:       "defaults",
+			pod:        newPod("pod-0", "medusa"),
+			hasSidecar: true,
+			address:    "10.0.0.1:50051",
+		},
+		{
+			name:       "named port",
+			pod:        newPod("pod-0", "medusa", grpcPort),
+			hasSidecar: true,
+			address:    "10.0.0.1:6000",
+		},
+		{
+			name: "datacenter annotations",
+			annotations: map[string]string{
+				medusa.SidecarContainerAnnotation: "backup",
+				medusa.SidecarPortAnnotation:      "7000",
+			},
+			pod:        newPod("pod-0", "backup", grpcPort),
+			hasSidecar: true,
+			address:    "10.0.0.1:7000",
+		},
+		{
+			name: "backup override",
+			annotations: map[string]string{
+				medusa.SidecarContainerAnnotation: "backup",
+				medusa.SidecarPortAnnotation:      "7000",
+			},
+			override:   &api.SidecarConfig{ContainerName: "medusa-sidecar", Port: 8000},
+			pod:        newPod("pod-0", "medusa-sidecar"),
+			hasSidecar: true,
+			address:    "10.0.0.1:8000",
+		},
+		{
+			name:       "sidecar not found",
+			pod:        newPod("pod-0", "backup"),
+			hasSidecar: false,
+			address:    "10.0.0.1:50051",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := &cassdcapi.CassandraDatacenter{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dc1", Annotations: tt.annotations},
+			}
+			dialer, err := newSidecarDialer(ctx, c, dc, tt.override, nil, medusa.TLSSettings{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.hasSidecar, dialer.hasSidecar(&tt.pod))
+			assert.Equal(t, tt.address, dialer.address(&tt.pod))
+			if tt.hasSidecar {
+				assert.Empty(t, dialer.podsWithoutSidecar([]corev1.Pod{tt.pod}))
+			} else {
+				assert.Equal(t, []string{"pod-0"}, dialer.podsWithoutSidecar([]corev1.Pod{tt.pod}))
+			}
+		})
+	}
+}
+
+// newTestCertificate returns a PEM encoded self-signed certificate and its private key.
+func newTestCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "medusa"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
@@ -0,0 +1,93 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// backupTasks keeps track of the Backup requests that run in the background so that they
+// can be cancelled. The zero value is ready to use.
+type backupTasks struct {
+	mutex sync.Mutex
+	tasks map[types.NamespacedName]map[string]*backupTask
+}
+
+type backupTask struct {
+	cancel context.CancelFunc
+}
+
+// start returns the context of the Backup request sent to the pod, with the given timeout
+// unless it is zero. The returned function must be called once the request completes.
+func (t *backupTasks) start(key types.NamespacedName, podName string, timeout time.Duration) (context.Context, func()) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	task := &backupTask{cancel: cancel}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.tasks == nil {
+		t.tasks = make(map[types.NamespacedName]map[string]*backupTask)
+	}
+	if t.tasks[key] == nil {
+		t.tasks[key] = make(map[string]*backupTask)
+	}
+	if previous := t.tasks[key][podName]; previous != nil {
+		previous.cancel()
+	}
+	t.tasks[key][podName] = task
+
+	return ctx, func() {
+		cancel()
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		// A retry may already have replaced the task.
+		if t.tasks[key][podName] == task {
+			delete(t.tasks[key], podName)
+			if len(t.tasks[key]) == 0 {
+				delete(t.tasks, key)
+			}
+		}
+	}
+}
+
+// cancel aborts the Backup request sent to the pod, if it is still running.
+func (t *backupTasks) cancel(key types.NamespacedName, podName string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if task := t.tasks[key][podName]; task != nil {
+		task.cancel()
+	}
+}
+
+// cancelAll aborts all the Backup requests of the backup that are still running.
+func (t *backupTasks) cancelAll(key types.NamespacedName) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, task := range t.tasks[key] {
+		task.cancel()
+	}
+}
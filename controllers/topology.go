@@ -0,0 +1,204 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+)
+
+// defaultRackName is the rack of the nodes of a datacenter that does not define racks.
+const defaultRackName = "default"
+
+// checkRestoreTopology compares the topology of the backup with the datacenter into which
+// it is restored. Medusa restores the data of each node of the backup on the node of the
+// datacenter that owns the same tokens in the same rack, so the size, the racks and the
+// number of tokens per node must match. The tokens that the nodes of the datacenter own
+// are not compared with those of the backup, since the operator has no way to read the
+// token ring of the running nodes. summary is the summary of the backup in storage and
+// targetRacks the number of nodes in each rack of the datacenter. It returns the mismatches
+// found, and false if neither the summary nor the datacenter template of the backup is
+// known, in which case nothing can be checked.
+func checkRestoreTopology(backup *api.CassandraBackup, summary *api.BackupSummary, target *cassdcapi.CassandraDatacenterSpec, targetRacks map[string]int) ([]string, bool) {
+	template := backup.Status.CassdcTemplateSpec
+	if summary == nil && template == nil {
+		return nil, false
+	}
+
+	var nodes []api.BackupNode
+	var size int
+	var backupRacks map[string]int
+	if summary != nil {
+		nodes = backupDatacenterNodes(backup.Spec.CassandraDatacenter, summary)
+		size = len(nodes)
+		backupRacks = nodeRackSizes(nodes)
+	} else {
+		size = int(template.Spec.Size)
+	}
+	if backupRacks == nil && template != nil && int(template.Spec.Size) == size {
+		// Medusa did not record the racks, they are taken from the template.
+		backupRacks = rackSizes(&template.Spec)
+	}
+
+	problems := make([]string, 0)
+	targetSize := 0
+	for _, count := range targetRacks {
+		targetSize += count
+	}
+	if size != targetSize {
+		problems = append(problems, fmt.Sprintf("the backup has %d nodes but the datacenter has %d", size, targetSize))
+	}
+
+	if backupRacks != nil {
+		if names, targetNames := sortedKeys(backupRacks), sortedKeys(targetRacks); !equalStrings(names, targetNames) {
+			problems = append(problems, fmt.Sprintf("the backup has racks %s but the datacenter has racks %s",
+				strings.Join(names, ", "), strings.Join(targetNames, ", ")))
+		} else if size == targetSize {
+			for _, rack := range names {
+				if backupRacks[rack] != targetRacks[rack] {
+					problems = append(problems, fmt.Sprintf("rack %s has %d nodes in the backup but %d in the datacenter", rack, backupRacks[rack], targetRacks[rack]))
+				}
+			}
+		}
+	}
+
+	tokens := -1
+	owners := make(map[int64]string)
+	var duplicate string
+	for _, node := range nodes {
+		if tokens >= 0 && len(node.Tokens) != tokens {
+			problems = append(problems, "the nodes of the backup own different numbers of tokens")
+			break
+		}
+		tokens = len(node.Tokens)
+		for _, token := range node.Tokens {
+			if owner, found := owners[token]; found && len(duplicate) == 0 {
+				duplicate = fmt.Sprintf("token %d is owned by both %s and %s in the backup", token, owner, node.Host)
+			}
+			owners[token] = node.Host
+		}
+	}
+	if len(duplicate) > 0 {
+		problems = append(problems, duplicate)
+	}
+	if numTokens, found := configuredNumTokens(target); found && tokens > 0 && tokens != numTokens {
+		problems = append(problems, fmt.Sprintf("the nodes of the backup own %d tokens but the datacenter is configured with num_tokens %d", tokens, numTokens))
+	}
+
+	return problems, true
+}
+
+// backupDatacenterNodes returns the nodes of the summary that belong to the datacenter.
+// Nodes without a datacenter are assumed to belong to it.
+func backupDatacenterNodes(datacenter string, summary *api.BackupSummary) []api.BackupNode {
+	nodes := make([]api.BackupNode, 0, len(summary.Nodes))
+	for _, node := range summary.Nodes {
+		if len(node.Datacenter) == 0 || node.Datacenter == datacenter {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// nodeRackSizes returns the number of nodes of the backup in each rack, or nil if Medusa
+// did not record the rack of every node.
+func nodeRackSizes(nodes []api.BackupNode) map[string]int {
+	sizes := make(map[string]int)
+	for _, node := range nodes {
+		if len(node.Rack) == 0 {
+			return nil
+		}
+		sizes[node.Rack]++
+	}
+	return sizes
+}
+
+// podRackSizes returns the number of pods in each rack of a datacenter.
+func podRackSizes(pods []corev1.Pod) map[string]int {
+	sizes := make(map[string]int)
+	for _, pod := range pods {
+		rack := pod.Labels[cassdcapi.RackLabel]
+		if len(rack) == 0 {
+			rack = defaultRackName
+		}
+		sizes[rack]++
+	}
+	return sizes
+}
+
+// rackSizes returns the number of nodes in each rack of the datacenter. Like cass-operator,
+// the nodes are spread evenly across the racks, the first racks getting the remainder.
+func rackSizes(spec *cassdcapi.CassandraDatacenterSpec) map[string]int {
+	racks := spec.Racks
+	if len(racks) == 0 {
+		racks = []cassdcapi.Rack{{Name: defaultRackName}}
+	}
+	sizes := make(map[string]int, len(racks))
+	size, count := int(spec.Size), len(racks)
+	for i, rack := range racks {
+		sizes[rack.Name] = size / count
+		if i < size%count {
+			sizes[rack.Name]++
+		}
+	}
+	return sizes
+}
+
+// configuredNumTokens returns the num_tokens set in the cassandra-yaml section of the
+// config of the datacenter, if any.
+func configuredNumTokens(spec *cassdcapi.CassandraDatacenterSpec) (int, bool) {
+	if len(spec.Config) == 0 {
+		return 0, false
+	}
+	config := struct {
+		CassandraYaml struct {
+			NumTokens *int `json:"num_tokens"`
+		} `json:"cassandra-yaml"`
+	}{}
+	if err := json.Unmarshal(spec.Config, &config); err != nil || config.CassandraYaml.NumTokens == nil {
+		return 0, false
+	}
+	return *config.CassandraYaml.NumTokens, true
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
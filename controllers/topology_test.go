@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"testing"
+
+	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
+	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTopologyBackup(template *cassdcapi.CassandraDatacenterSpec) *api.CassandraBackup {
+	backup := &api.CassandraBackup{
+		Spec: api.CassandraBackupSpec{CassandraDatacenter: "dc1"},
+	}
+	if template != nil {
+		backup.Status.CassdcTemplateSpec = &api.CassandraDatacenterTemplateSpec{Spec: *template}
+	}
+	return backup
+}
+
+func newTopologySummary(nodes ...api.BackupNode) *api.BackupSummary {
+	return &api.BackupSummary{Nodes: nodes}
+}
+
+func TestCheckRestoreTopology(t *testing.T) {
+	assert := assert.New(t)
+
+	racks := []cassdcapi.Rack{{Name: "rack1"}, {Name: "rack2"}}
+	target := &cassdcapi.CassandraDatacenterSpec{Size: 3, Racks: racks}
+	targetRacks := map[string]int{"rack1": 2, "rack2": 1}
+
+	problems, known := checkRestoreTopology(newTopologyBackup(nil), nil, target, targetRacks)
+	assert.False(known, "nothing can be checked without a template or a summary")
+	assert.Empty(problems)
+
+	problems, known = checkRestoreTopology(newTopologyBackup(&cassdcapi.CassandraDatacenterSpec{Size: 3, Racks: racks}), nil, target, targetRacks)
+	assert.True(known)
+	assert.Empty(problems)
+
+	problems, _ = checkRestoreTopology(newTopologyBackup(&cassdcapi.CassandraDatacenterSpec{Size: 6}), nil, target, targetRacks)
+	assert.Len(problems, 2, "the size and the racks differ")
+
+	problems, _ = checkRestoreTopology(newTopologyBackup(&cassdcapi.CassandraDatacenterSpec{Size: 3, Racks: racks}), nil, target, map[string]int{"rack1": 1, "rack2": 2})
+	assert.Len(problems, 2, "the live racks of the datacenter are compared")
+
+	nodes := []api.BackupNode{
+		{Host: "10.0.0.1", Rack: "rack1", Tokens: []int64{1}},
+		{Host: "10.0.0.2", Rack: "rack2", Tokens: []int64{2}},
+		{Host: "10.0.0.3", Rack: "rack1", Tokens: []int64{3}},
+		{Host: "10.0.1.1", Datacenter: "dc2", Rack: "rack1", Tokens: []int64{1}},
+	}
+	problems, known = checkRestoreTopology(newTopologyBackup(nil), newTopologySummary(nodes...), target, targetRacks)
+	assert.True(known)
+	assert.Empty(problems, "the nodes of other datacenters are ignored")
+
+	problems, _ = checkRestoreTopology(newTopologyBackup(&cassdcapi.CassandraDatacenterSpec{Size: 3, Racks: racks}), newTopologySummary(nodes[0], nodes[1]), target, targetRacks)
+	assert.Equal([]string{"the backup has 2 nodes but the datacenter has 3"}, problems, "the size is only reported once")
+
+	problems, _ = checkRestoreTopology(newTopologyBackup(nil), newTopologySummary(nodes[0], nodes[2], nodes[2]), target, targetRacks)
+	assert.Len(problems, 2, "the racks differ and a token is owned twice")
+
+	unknownRacks := []api.BackupNode{{Host: "10.0.0.1", Tokens: []int64{1}}, {Host: "10.0.0.2", Tokens: []int64{2}}, {Host: "10.0.0.3", Tokens: []int64{3}}}
+	problems, _ = checkRestoreTopology(newTopologyBackup(nil), newTopologySummary(unknownRacks...), target, targetRacks)
+	assert.Empty(problems, "the racks are not compared when they are not recorded")
+
+	problems, _ = checkRestoreTopology(newTopologyBackup(&cassdcapi.CassandraDatacenterSpec{Size: 3}), newTopologySummary(unknownRacks...), target, targetRacks)
+	assert.Len(problems, 1, "the racks of the template are compared when the nodes do not record them")
+
+	unknownRacks[2].Tokens = []int64{3, 4}
+	problems, _ = checkRestoreTopology(newTopologyBackup(nil), newTopologySummary(unknownRacks...), target, targetRacks)
+	assert.Equal([]string{"the nodes of the backup own different numbers of tokens"}, problems)
+
+	unknownRacks[2].Tokens = []int64{3}
+	target.Config = []byte(`{"cassandra-yaml": {"num_tokens": 16}}`)
+	problems, _ = checkRestoreTopology(newTopologyBackup(nil), newTopologySummary(unknownRacks...), target, targetRacks)
+	assert.Len(problems, 1, "num_tokens differs")
+}
+
+func TestRackSizes(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(map[string]int{defaultRackName: 3}, rackSizes(&cassdcapi.CassandraDatacenterSpec{Size: 3}))
+	assert.Equal(map[string]int{"r1": 2, "r2": 2, "r3": 1}, rackSizes(&cassdcapi.CassandraDatacenterSpec{
+		Size:  5,
+		Racks: []cassdcapi.Rack{{Name: "r1"}, {Name: "r2"}, {Name: "r3"}},
+	}))
+}
+
+func TestPodRackSizes(t *testing.T) {
+	pod := func(rack string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{cassdcapi.RackLabel: rack}}}
+	}
+	assert.Equal(t, map[string]int{"r1": 2, "r2": 1, defaultRackName: 1}, podRackSizes([]corev1.Pod{pod("r1"), pod("r2"), pod("r1"), {}}))
+}
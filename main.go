@@ -22,8 +22,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/k8ssandra/medusa-operator/pkg/cassandra"
 	"github.com/k8ssandra/medusa-operator/pkg/medusa"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -54,14 +56,37 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var defaultTLS medusa.TLSSettings
+	var backupNameTemplate string
+	var medusaClientFactory medusa.DefaultFactory
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultTLS.SecretName, "medusa-tls-secret", "",
+		"The name of the Secret with the TLS material for the gRPC connections to the Medusa sidecars. "+
+			"It must contain ca.crt, and tls.crt and tls.key for mTLS. TLS is disabled when empty. "+
+			"Can be overridden per CassandraDatacenter with the "+medusa.TLSSecretAnnotation+" annotation.")
+	flag.StringVar(&defaultTLS.ServerName, "medusa-tls-server-name", "",
+		"The server name used to verify the certificates of the Medusa sidecars. "+
+			"Can be overridden per CassandraDatacenter with the "+medusa.TLSServerNameAnnotation+" annotation.")
+	flag.StringVar(&backupNameTemplate, "backup-name-template", api.DefaultBackupNameTemplate,
+		"The Go template used to generate the names of backups that do not set one. "+
+			"The available fields are .Namespace, .Datacenter, .Type and .Timestamp.")
+	flag.DurationVar(&medusaClientFactory.DialTimeout, "medusa-dial-timeout", medusa.DefaultDialTimeout,
+		"How long to wait for the gRPC connection to a Medusa sidecar.")
+	flag.DurationVar(&medusaClientFactory.RequestTimeout, "medusa-request-timeout", medusa.DefaultRequestTimeout,
+		"The deadline of the gRPC requests to the Medusa sidecars. "+
+			"Backups are bounded by the timeouts of CassandraBackup instead.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if err := api.SetBackupNameTemplate(backupNameTemplate); err != nil {
+		setupLog.Error(err, "invalid backup name template")
+		os.Exit(1)
+	}
+
 	watchNamespace, err := getWatchNamespace()
 	if err != nil {
 		setupLog.Error(err, "unable to get WatchNamespace, "+
@@ -92,24 +117,82 @@ func main() {
 		os.Exit(1)
 	}
 
+	podExecutor, err := cassandra.NewRemoteExecutor(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create the pod executor")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.CassandraBackupReconciler{
 		Client:        mgr.GetClient(),
 		Log:           ctrl.Log.WithName("controllers").WithName("CassandraBackup"),
 		Scheme:        mgr.GetScheme(),
-		ClientFactory: &medusa.DefaultFactory{},
+		Recorder:      mgr.GetEventRecorderFor("cassandrabackup-controller"),
+		ClientFactory: &medusaClientFactory,
+		DefaultTLS:    defaultTLS,
+		RequeueAfter:  10 * time.Second,
+		PodExecutor:   podExecutor,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CassandraBackup")
 		os.Exit(1)
 	}
 	if err = (&controllers.CassandraRestoreReconciler{
-		Client:       mgr.GetClient(),
-		Log:          ctrl.Log.WithName("controllers").WithName("CassandraRestore"),
-		Scheme:       mgr.GetScheme(),
-		RequeueAfter: 10 * time.Second,
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("CassandraRestore"),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("cassandrarestore-controller"),
+		RequeueAfter:  10 * time.Second,
+		ClientFactory: &medusaClientFactory,
+		DefaultTLS:    defaultTLS,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CassandraRestore")
 		os.Exit(1)
 	}
+	if err = (&controllers.CassandraBackupScheduleReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("CassandraBackupSchedule"),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("cassandrabackupschedule-controller"),
+		Clock:         clock.RealClock{},
+		ClientFactory: &medusaClientFactory,
+		DefaultTLS:    defaultTLS,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CassandraBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.CassandraClusterBackupReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("CassandraClusterBackup"),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("cassandraclusterbackup-controller"),
+		RequeueAfter:  10 * time.Second,
+		ClientFactory: &medusaClientFactory,
+		DefaultTLS:    defaultTLS,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CassandraClusterBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.CassandraBackupSyncReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("CassandraBackupSync"),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("cassandrabackupsync-controller"),
+		ClientFactory: &medusaClientFactory,
+		DefaultTLS:    defaultTLS,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CassandraBackupSync")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&api.CassandraBackup{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CassandraBackup")
+			os.Exit(1)
+		}
+		if err = (&api.CassandraRestore{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CassandraRestore")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,117 @@
+package cassandra
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PodExecutor runs commands in the containers of pods.
+type PodExecutor interface {
+	// Exec runs the command in the container of the pod and returns its standard output.
+	// It fails if the command exits with a non-zero status.
+	Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error)
+}
+
+// RemoteExecutor runs commands through the exec subresource of the Kubernetes API.
+type RemoteExecutor struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+}
+
+// NewRemoteExecutor returns a RemoteExecutor that connects to the API server with config.
+func NewRemoteExecutor(config *rest.Config) (*RemoteExecutor, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteExecutor{config: config, clientset: clientset}, nil
+}
+
+func (e *RemoteExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error) {
+	request := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	transport, upgrader, err := spdy.RoundTripperFor(e.config)
+	if err != nil {
+		return "", err
+	}
+	connections := &closableUpgrader{Upgrader: upgrader}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, connections, "POST", request.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	}()
+
+	// The stream does not take a context, so its connection is closed when the context
+	// expires, which makes it return.
+	select {
+	case <-ctx.Done():
+		connections.close()
+		<-done
+		return "", fmt.Errorf("command %s in pod %s: %s", command[0], pod.Name, ctx.Err())
+	case err := <-done:
+		if err != nil {
+			return stdout.String(), fmt.Errorf("command %s in pod %s: %s: %s", command[0], pod.Name, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), nil
+	}
+}
+
+// closableUpgrader keeps track of the connection created by the upgrader so that it can be
+// closed from another goroutine. A connection created after close is closed right away.
+type closableUpgrader struct {
+	spdy.Upgrader
+	mutex      sync.Mutex
+	connection httpstream.Connection
+	closed     bool
+}
+
+func (u *closableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	connection, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.closed {
+		connection.Close()
+	}
+	u.connection = connection
+	return connection, nil
+}
+
+func (u *closableUpgrader) close() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.closed = true
+	if u.connection != nil {
+		u.connection.Close()
+	}
+}
@@ -6,4 +6,8 @@ var (
 	// This error indicates that a pod (or pods) do not include the medusa backup sidecar
 	// container.
 	BackupSidecarNotFound = errors.New("the backup sidecar was not found")
+
+	// This error indicates that the CassandraDatacenter of a backup does not exist, so there
+	// is no sidecar through which the backup can be deleted from storage.
+	DatacenterNotFound = errors.New("the CassandraDatacenter was not found")
 )
@@ -2,56 +2,107 @@ package medusa
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
 	"github.com/k8ssandra/medusa-operator/pkg/pb"
 )
 
+const (
+	// DefaultDialTimeout is how long to wait for the connection to a sidecar.
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultRequestTimeout is the deadline of the gRPC requests to the sidecars, except
+	// for Backup which runs as long as its context allows.
+	DefaultRequestTimeout = time.Minute
+)
+
 type defaultClient struct {
-	connection *grpc.ClientConn
-	grpcClient pb.MedusaClient
+	connection     *grpc.ClientConn
+	grpcClient     pb.MedusaClient
+	requestTimeout time.Duration
 }
 
 type ClientFactory interface {
-	NewClient(address string) (Client, error)
+	// NewClient connects to the sidecar at address. The connection is secured with
+	// tlsConfig unless it is nil.
+	NewClient(address string, tlsConfig *tls.Config) (Client, error)
 }
 
 type DefaultFactory struct {
+	// DialTimeout defaults to DefaultDialTimeout when zero.
+	DialTimeout time.Duration
+
+	// RequestTimeout defaults to DefaultRequestTimeout when zero.
+	RequestTimeout time.Duration
 }
 
-func (f *DefaultFactory) NewClient(address string) (Client, error) {
-	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithDefaultCallOptions(grpc.WaitForReady(false)))
+func (f *DefaultFactory) NewClient(address string, tlsConfig *tls.Config) (Client, error) {
+	transportOption := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transportOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), durationOrDefault(f.DialTimeout, DefaultDialTimeout))
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address, transportOption, grpc.WithBlock(), grpc.WithDefaultCallOptions(grpc.WaitForReady(false)),
+		grpc.WithUnaryInterceptor(metricsInterceptor))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC connection to %s: %s", address, err)
 	}
 
-	return &defaultClient{connection: conn, grpcClient: pb.NewMedusaClient(conn)}, nil
+	return &defaultClient{
+		connection:     conn,
+		grpcClient:     pb.NewMedusaClient(conn),
+		requestTimeout: durationOrDefault(f.RequestTimeout, DefaultRequestTimeout),
+	}, nil
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultDuration
+	}
+	return d
 }
 
 type Client interface {
 	Close() error
 
-	CreateBackup(ctx context.Context, name string, backupType string) error
+	// CreateBackup backs up the keyspaces of the node, or all of them if keyspaces is empty,
+	// except for the ones in excludeKeyspaces. It blocks until the backup is complete, so
+	// the deadline of the backup is left to ctx. Cancelling ctx aborts the request.
+	CreateBackup(ctx context.Context, name string, backupType string, keyspaces, excludeKeyspaces []string) error
 
 	GetBackups(ctx context.Context) ([]*pb.BackupSummary, error)
+
+	BackupStatus(ctx context.Context, name string) (*pb.BackupStatusResponse, error)
+
+	DeleteBackup(ctx context.Context, name string) error
 }
 
 func (c *defaultClient) Close() error {
 	return c.connection.Close()
 }
 
-func (c *defaultClient) CreateBackup(ctx context.Context, name string, backupType string) error {
+func (c *defaultClient) CreateBackup(ctx context.Context, name string, backupType string, keyspaces, excludeKeyspaces []string) error {
 	backupMode := pb.BackupRequest_DIFFERENTIAL
 	if backupType == "full" {
 		backupMode = pb.BackupRequest_FULL
 	}
 
 	request := pb.BackupRequest{
-		Name: name,
-		Mode: backupMode,
+		Name:             name,
+		Mode:             backupMode,
+		Keyspaces:        keyspaces,
+		ExcludeKeyspaces: excludeKeyspaces,
 	}
 	_, err := c.grpcClient.Backup(ctx, &request)
 
@@ -59,6 +110,8 @@ func (c *defaultClient) CreateBackup(ctx context.Context, name string, backupTyp
 }
 
 func (c *defaultClient) GetBackups(ctx context.Context) ([]*pb.BackupSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
 	response, err := c.grpcClient.GetBackups(ctx, &pb.GetBackupsRequest{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get backups: %s", err)
@@ -66,8 +119,28 @@ func (c *defaultClient) GetBackups(ctx context.Context) ([]*pb.BackupSummary, er
 	return response.Backups, nil
 }
 
+func (c *defaultClient) BackupStatus(ctx context.Context, name string) (*pb.BackupStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+	request := pb.BackupStatusRequest{BackupName: name}
+	response, err := c.grpcClient.BackupStatus(ctx, &request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of backup %s: %w", name, err)
+	}
+	return response, nil
+}
+
 func (c *defaultClient) DeleteBackup(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
 	request := pb.DeleteBackupRequest{Name: name}
-	_, err := c.grpcClient.DeleteBackup(context.Background(), &request)
+	_, err := c.grpcClient.DeleteBackup(ctx, &request)
 	return err
 }
+
+// IsNotFound returns true if err is the gRPC error of a sidecar that does not know the
+// requested backup.
+func IsNotFound(err error) bool {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	return errors.As(err, &grpcErr) && grpcErr.GRPCStatus().Code() == codes.NotFound
+}
@@ -0,0 +1,41 @@
+package medusa
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	grpcRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "medusa_operator",
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC requests to the Medusa sidecars",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"method"})
+
+	grpcRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "medusa_operator",
+		Name:      "grpc_request_errors_total",
+		Help:      "Number of gRPC requests to the Medusa sidecars that failed, by status code",
+	}, []string{"method", "code"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(grpcRequestDuration, grpcRequestErrors)
+}
+
+// metricsInterceptor records the latency and the errors of unary gRPC calls.
+func metricsInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	grpcRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		grpcRequestErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package medusa
+
+const (
+	// DefaultSidecarContainerName is the name of the Medusa sidecar container unless it is
+	// overridden.
+	DefaultSidecarContainerName = "medusa"
+
+	// SidecarContainerAnnotation can be set on a CassandraDatacenter to the name of the
+	// Medusa sidecar container when it differs from the default.
+	SidecarContainerAnnotation = "medusa.k8ssandra.io/sidecar-container"
+
+	// SidecarPortAnnotation can be set on a CassandraDatacenter to the port of the gRPC
+	// server of the Medusa sidecar. It takes precedence over the named container port.
+	SidecarPortAnnotation = "medusa.k8ssandra.io/sidecar-port"
+
+	// SidecarPortName is the name of the container port of the Medusa sidecar that is
+	// used for gRPC when the port is not set explicitly.
+	SidecarPortName = "grpc"
+
+	// RestoreContainerAnnotation can be set on a CassandraDatacenter to the name of the
+	// Medusa restore init container when it differs from the default.
+	RestoreContainerAnnotation = "medusa.k8ssandra.io/restore-container"
+)
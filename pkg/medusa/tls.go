@@ -0,0 +1,78 @@
+package medusa
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// TLSSecretAnnotation can be set on a CassandraDatacenter to the name of the Secret
+	// that holds the TLS material for the connections to its Medusa sidecars. It
+	// overrides the Secret configured for the manager.
+	TLSSecretAnnotation = "medusa.k8ssandra.io/tls-secret"
+
+	// TLSServerNameAnnotation can be set on a CassandraDatacenter to override the server
+	// name that is used to verify the certificates of its Medusa sidecars.
+	TLSServerNameAnnotation = "medusa.k8ssandra.io/tls-server-name"
+
+	// TLSCAKey is the key of the CA bundle in the TLS Secret. It is required.
+	TLSCAKey = "ca.crt"
+)
+
+// TLSSettings identifies the Secret that holds the TLS material for the gRPC connections
+// to the Medusa sidecars. The Secret must contain a CA bundle under ca.crt. When it also
+// contains tls.crt and tls.key, they are presented as client certificate for mTLS. TLS
+// is disabled when SecretName is empty.
+type TLSSettings struct {
+	SecretName string
+
+	// ServerName is used to verify the certificates of the sidecars. It defaults to the
+	// address of the sidecar, which is the pod IP.
+	ServerName string
+}
+
+// Enabled returns true if the connections have to be secured with TLS.
+func (s TLSSettings) Enabled() bool {
+	return len(s.SecretName) > 0
+}
+
+// WithAnnotations returns the settings overridden by the TLS annotations of a
+// CassandraDatacenter.
+func (s TLSSettings) WithAnnotations(annotations map[string]string) TLSSettings {
+	if secretName, found := annotations[TLSSecretAnnotation]; found {
+		s.SecretName = secretName
+	}
+	if serverName, found := annotations[TLSServerNameAnnotation]; found {
+		s.ServerName = serverName
+	}
+	return s
+}
+
+// NewTLSConfig creates the client TLS configuration from the contents of the TLS Secret.
+func NewTLSConfig(secret *corev1.Secret, serverName string) (*tls.Config, error) {
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(secret.Data[TLSCAKey]) {
+		return nil, fmt.Errorf("secret %s does not contain a valid CA bundle under %s", secret.Name, TLSCAKey)
+	}
+
+	config := &tls.Config{
+		RootCAs:    caPool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	cert, hasCert := secret.Data[corev1.TLSCertKey]
+	key, hasKey := secret.Data[corev1.TLSPrivateKeyKey]
+	if hasCert || hasKey {
+		keyPair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s does not contain a valid client certificate: %s", secret.Name, err)
+		}
+		config.Certificates = []tls.Certificate{keyPair}
+	}
+
+	return config, nil
+}
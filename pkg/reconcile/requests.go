@@ -10,6 +10,7 @@ import (
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
 	api "github.com/k8ssandra/medusa-operator/api/v1alpha1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubernetes/pkg/util/hash"
@@ -22,12 +23,17 @@ type RestoreRequest struct {
 
 	Restore *api.CassandraRestore
 
+	// Backup is nil if the restore selects its backup and none matches.
 	Backup *api.CassandraBackup
 
+	// Datacenter is nil for a remote restore until the new datacenter has been created.
 	Datacenter *cassdcapi.CassandraDatacenter
 
 	restoreHash string
 
+	// onApplied are called once the updates of the request have been persisted.
+	onApplied []func()
+
 	datacenterHash string
 
 	restorePatch client.Patch
@@ -68,11 +74,9 @@ func (f *factory) NewRestoreRequest(ctx context.Context, restoreKey types.Namesp
 		return nil, &ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 
-	backup := &api.CassandraBackup{}
-	backupKey := types.NamespacedName{Namespace: restoreKey.Namespace, Name: restore.Spec.Backup}
-	err = f.Get(ctx, backupKey, backup)
+	backup, err := f.getBackup(ctx, restore)
 	if err != nil {
-		f.Log.Error(err, "Failed to get CassandraBackup", "CassandraBackup", backupKey)
+		f.Log.Error(err, "Failed to get CassandraBackup")
 		return nil, &ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 
@@ -80,33 +84,63 @@ func (f *factory) NewRestoreRequest(ctx context.Context, restoreKey types.Namesp
 	dcKey := types.NamespacedName{Namespace: restoreKey.Namespace, Name: restore.Spec.CassandraDatacenter.Name}
 	err = f.Get(ctx, dcKey, dc)
 	if err != nil {
-		// TODO The datacenter does not have to exist for a remote restore
-		f.Log.Error(err, "Failed to get CassandraDatacenter", "CassandraDatacenter", dcKey)
-		return nil, &ctrl.Result{RequeueAfter: 10 * time.Second}, err
+		if errors.IsNotFound(err) && !restore.Spec.InPlace {
+			// The datacenter is created by the remote restore.
+			dc = nil
+		} else {
+			f.Log.Error(err, "Failed to get CassandraDatacenter", "CassandraDatacenter", dcKey)
+			return nil, &ctrl.Result{RequeueAfter: 10 * time.Second}, err
+		}
 	}
 
 	reqLogger := f.Log.WithValues(
 		"CassandraRestore", restoreKey,
-		"CassandraBackup", backupKey,
 		"CassandraDatacenter", dcKey)
 
-	restoreHash := deepHashString(restore.Status)
-	datacenterHash := deepHashString(dc.Spec)
-
 	req := RestoreRequest{
-		Log:             reqLogger,
-		Restore:         restore.DeepCopy(),
-		Backup:          backup.DeepCopy(),
-		Datacenter:      dc.DeepCopy(),
-		restoreHash:     restoreHash,
-		datacenterHash:  datacenterHash,
-		restorePatch:    client.MergeFromWithOptions(restore.DeepCopy(), client.MergeFromWithOptimisticLock{}),
-		datacenterPatch: client.MergeFromWithOptions(dc.DeepCopy(), client.MergeFromWithOptimisticLock{}),
+		Log:          reqLogger,
+		Restore:      restore.DeepCopy(),
+		restoreHash:  deepHashString(restore.Status),
+		restorePatch: client.MergeFromWithOptions(restore.DeepCopy(), client.MergeFromWithOptimisticLock{}),
+	}
+
+	if backup != nil {
+		req.Backup = backup.DeepCopy()
+		req.Log = reqLogger.WithValues("CassandraBackup", types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name})
+	}
+
+	if dc != nil {
+		req.Datacenter = dc.DeepCopy()
+		req.datacenterHash = deepHashString(dc.Spec)
+		req.datacenterPatch = client.MergeFromWithOptions(dc.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	}
 
 	return &req, nil, nil
 }
 
+// getBackup returns the CassandraBackup to restore: the backup recorded in the status once
+// the restore has started, else the backup named in the spec, else the backup chosen by the
+// backup selector and the point in time to restore. It returns nil if no backup is chosen.
+func (f *factory) getBackup(ctx context.Context, restore *api.CassandraRestore) (*api.CassandraBackup, error) {
+	name := restore.Status.Backup
+	if len(name) == 0 {
+		name = restore.Spec.Backup
+	}
+	if len(name) > 0 || (restore.Spec.BackupSelector == nil && restore.Spec.RestorePointInTime == nil) {
+		backup := &api.CassandraBackup{}
+		if err := f.Get(ctx, types.NamespacedName{Namespace: restore.Namespace, Name: name}, backup); err != nil {
+			return nil, err
+		}
+		return backup, nil
+	}
+
+	backups := &api.CassandraBackupList{}
+	if err := f.List(ctx, backups, client.InNamespace(restore.Namespace)); err != nil {
+		return nil, err
+	}
+	return restore.Spec.SelectBackup(backups.Items), nil
+}
+
 // RestoreModified returns true if the CassandraRestore.Status has been modified.
 func (r *RestoreRequest) RestoreModified() bool {
 	return deepHashString(r.Restore.Status) != r.restoreHash
@@ -114,9 +148,26 @@ func (r *RestoreRequest) RestoreModified() bool {
 
 // DatacenterModified returns true if the CassandraDatacenter.Spec has been modified.
 func (r *RestoreRequest) DatacenterModified() bool {
+	if r.Datacenter == nil {
+		return false
+	}
 	return deepHashString(r.Datacenter.Spec) != r.datacenterHash
 }
 
+// OnApplied registers f to be called once the updates of the request have been persisted.
+func (r *RestoreRequest) OnApplied(f func()) {
+	r.onApplied = append(r.onApplied, f)
+}
+
+// Applied calls the functions registered with OnApplied. It must be called once the updates
+// of the request have been persisted.
+func (r *RestoreRequest) Applied() {
+	for _, f := range r.onApplied {
+		f()
+	}
+	r.onApplied = nil
+}
+
 // SetRestoreKey sets the key. Note that this function is idempotent.
 func (r *RestoreRequest) SetRestoreKey(key string) {
 	if len(r.Restore.Status.RestoreKey) == 0 {
@@ -138,10 +189,55 @@ func (r *RestoreRequest) SetDatacenterStoppedTime(t metav1.Time) {
 	}
 }
 
+// SetDatacenterUpdatedTime sets the time at which the datacenter update completed. Note that
+// this function is idempotent.
+func (r *RestoreRequest) SetDatacenterUpdatedTime(t metav1.Time) {
+	if r.Restore.Status.DatacenterUpdated.IsZero() {
+		r.Restore.Status.DatacenterUpdated = t
+	}
+}
+
+// SetDatacenterCreatedTime sets the time at which the datacenter was created for a remote
+// restore. Note that this function is idempotent.
+func (r *RestoreRequest) SetDatacenterCreatedTime(t metav1.Time) {
+	if r.Restore.Status.DatacenterCreated.IsZero() {
+		r.Restore.Status.DatacenterCreated = t
+	}
+}
+
+// SetBackup records the name of the CassandraBackup that is restored. Note that this
+// function is idempotent.
+func (r *RestoreRequest) SetBackup(name string) {
+	if len(r.Restore.Status.Backup) == 0 {
+		r.Restore.Status.Backup = name
+	}
+}
+
+// SetReplayHorizon sets the point in time passed to the restore container. Note that this
+// function is idempotent.
+func (r *RestoreRequest) SetReplayHorizon(t metav1.Time) {
+	if r.Restore.Status.ReplayHorizon.IsZero() {
+		r.Restore.Status.ReplayHorizon = t
+	}
+}
+
 func (r *RestoreRequest) SetRestoreFinishTime(time metav1.Time) {
 	r.Restore.Status.FinishTime = time
 }
 
+// SetCondition adds or updates the condition and recomputes the phase. Note that this
+// function is idempotent; the transition time only changes along with the status.
+func (r *RestoreRequest) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&r.Restore.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: r.Restore.Generation,
+	})
+	r.Restore.Status.Phase = computeRestorePhase(r.Restore.Status.Conditions)
+}
+
 // GetRestorePatch returns a patch that can be used to apply changes to the CassandraRestore.
 // The patch is created when the RestoreRequest is initialized.
 func (r *RestoreRequest) GetRestorePatch() client.Patch {
@@ -154,6 +250,19 @@ func (r *RestoreRequest) GetDatacenterPatch() client.Patch {
 	return r.datacenterPatch
 }
 
+func computeRestorePhase(conditions []metav1.Condition) api.RestorePhase {
+	switch {
+	case meta.IsStatusConditionTrue(conditions, api.RestoreConditionFailed):
+		return api.RestorePhaseFailed
+	case meta.IsStatusConditionTrue(conditions, api.RestoreConditionRestored):
+		return api.RestorePhaseSucceeded
+	case meta.IsStatusConditionTrue(conditions, api.RestoreConditionStarted):
+		return api.RestorePhaseRunning
+	default:
+		return api.RestorePhasePending
+	}
+}
+
 func deepHashString(obj interface{}) string {
 	hasher := sha256.New()
 	hash.DeepHashObject(hasher, obj)